@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+/*
+ * AddOptions
+ *
+ * The flags of `configpp add`. `repo` is the config's path in ConfigsSrc, and `key` is
+ * the `InstallPaths` key the install path is stored under.
+ */
+type AddOptions struct {
+	repo string
+	key  string
+}
+
+/*
+ * `configpp add <name> <install path>` copies an installed config into ConfigsSrc and
+ * adds it to the manifest.
+ *
+ * When there is no manifest yet, it is created from the built-in configs first.
+ */
+func runAdd(args []string, options AddOptions) error {
+	if len(args) != 2 {
+		return errors.New("expected a name and an install path, i.e. `configpp add zsh ~/.zshrc`")
+	}
+
+	name := args[0]
+	installPath, err := filepath.Abs(replaceTildeInPath(args[1]))
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(installPath)
+	if err != nil {
+		return fmt.Errorf("nothing to add: %w", err)
+	}
+
+	manifest, err := readManifest(*FlagManifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("\nNo manifest at [%s]; creating it from the built-in configs\n", *FlagManifest)
+		for _, config := range Configs {
+			manifest.Configs = append(manifest.Configs, toManifestEntry(config))
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if slices.ContainsFunc(manifest.Configs, func(entry ManifestEntry) bool { return entry.Name == name }) {
+		return fmt.Errorf("the manifest already has a config named %q", name)
+	}
+
+	if options.key == "" {
+		options.key = DefaultInstallKey
+	}
+
+	repo := options.repo
+	if repo == "" {
+		repo = name
+		if !info.IsDir() {
+			repo = path.Join(name, path.Base(installPath))
+		}
+	}
+
+	entry := ManifestEntry{
+		Name:    name,
+		Dir:     info.IsDir(),
+		Repo:    repo,
+		Install: InstallPaths{options.key: replaceHomeWithTilde(installPath)},
+	}
+
+	manifest.Configs = append(manifest.Configs, entry)
+	if err := validateManifest(manifest); err != nil {
+		return err
+	}
+
+	config := entry.toConfig()
+	if _, err := os.Stat(config.localDotfilesRepoPath); err == nil {
+		return fmt.Errorf("[%s] already exists; choose another path with -repo", config.localDotfilesRepoPath)
+	}
+
+	exclude, err := getExcludePatterns(config)
+	if err != nil {
+		return err
+	}
+
+	// NOTE: copied from `installPath` directly because `-key` may not apply to this machine
+	summary, err := syncPath(installPath, config.localDotfilesRepoPath, SyncOptions{
+		DryRun:  *FlagDryRun,
+		Exclude: exclude,
+	})
+	if err != nil {
+		return fmt.Errorf("copying [%s] into [%s]: %w", installPath, config.localDotfilesRepoPath, err)
+	}
+
+	fmt.Printf("-----------------------------------\n")
+	fmt.Printf("\nCopying [%s]\n", name)
+	summary.config = name
+	printChangeSummary(summary)
+
+	if *FlagDryRun {
+		fmt.Printf("\n[dry-run] Would add [%s] to [%s]\n", name, *FlagManifest)
+		return nil
+	}
+
+	if err := saveManifest(*FlagManifest, manifest); err != nil {
+		return fmt.Errorf("saving manifest: %w", err)
+	}
+
+	fmt.Printf("\nAdded [%s] to [%s]; commit it in [%s] to share it with your other machines\n", name, *FlagManifest, ConfigsSrc)
+
+	return nil
+}
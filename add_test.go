@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestRunAdd(t *testing.T) {
+	configsSrc := configsSandbox(t, "")
+
+	installDir := t.TempDir()
+	executeCommand(installDir, "bash", "-c", "touch .zshrc && mkdir -p zellij && touch zellij/config.kdl")
+	installFile := path.Join(installDir, ".zshrc")
+
+	// Dry runs change nothing
+	*FlagDryRun = true
+	err := runAdd([]string{"zsh", installFile}, AddOptions{})
+	*FlagDryRun = false
+	if err != nil {
+		t.Fatalf("Unexpected error during a dry run: %v", err)
+	}
+	if _, err := os.Stat(path.Join(configsSrc, ManifestFile)); err == nil {
+		t.Errorf("Expected a dry run not to write the manifest")
+	}
+
+	// Happy path - without a manifest, it is created from the built-in configs
+	if err := runAdd([]string{"zsh", installFile}, AddOptions{}); err != nil {
+		t.Fatalf("Unexpected error adding a file: %v", err)
+	}
+	if _, err := os.Stat(path.Join(configsSrc, "zsh", ".zshrc")); err != nil {
+		t.Errorf("Expected .zshrc to be copied into the repo: %v", err)
+	}
+
+	if err := runAdd([]string{"zellij-linux", path.Join(installDir, "zellij")}, AddOptions{repo: "zellij-linux", key: "linux"}); err != nil {
+		t.Fatalf("Unexpected error adding a directory: %v", err)
+	}
+
+	configs, err := loadManifest(*FlagManifest)
+	if err != nil {
+		t.Fatalf("Unexpected error loading the written manifest: %v", err)
+	}
+	if len(configs) != len(Configs)+2 {
+		t.Errorf("Expected the built-in configs and both added configs; got %d configs", len(configs))
+	}
+
+	added := configs[len(configs)-1]
+	if !added.dir || added.localInstallPath["linux"] != path.Join(installDir, "zellij") || added.localDotfilesRepoPath != path.Join(configsSrc, "zellij-linux") {
+		t.Errorf("Added config (%+v) not as expected", added)
+	}
+
+	// Sad path - duplicate names, missing paths, and wrong arguments
+	for _, args := range [][]string{{"zsh", installFile}, {"missing", path.Join(installDir, "missing")}, {"zsh"}} {
+		if err := runAdd(args, AddOptions{}); err == nil {
+			t.Errorf("Expected an error adding %v", args)
+		}
+	}
+
+	manifest, _ := os.ReadFile(*FlagManifest)
+	if !strings.Contains(string(manifest), `"install": "`+installFile+`"`) {
+		t.Errorf("Expected a default install path to be written as a string:\n%s", manifest)
+	}
+}
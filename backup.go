@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"time"
+)
+
+// Layout of backup IDs, which are also the names of their directories; the fraction
+// keeps runs started within the same second, such as a pull and then a push, apart
+const BackupIDLayout = "20060102-150405.000000"
+
+/*
+ * Backup
+ *
+ * A snapshot of every local file a downstream run was about to overwrite, stored in
+ * `getStateDir()`/backups/<ID>/<config>/ with a backup.json describing how to roll back.
+ */
+type Backup struct {
+	ID      string        `json:"id"`
+	Entries []BackupEntry `json:"entries"`
+	created bool
+}
+
+/*
+ * BackupEntry
+ *
+ * `Root` is the directory the config was copied into; `Modified` and `Added` are
+ * relative to it. Modified files, which include the files the run deleted, are
+ * snapshotted, and added files did not exist before the run, so rolling back removes
+ * them.
+ *
+ * `Linked` entries are install paths a symlinked config replaced with a link. `Root`
+ * was moved into the backup whole, and rolling back puts it back in place of the link.
+ */
+type BackupEntry struct {
+	Config   string   `json:"config"`
+	Root     string   `json:"root"`
+	Modified []string `json:"modified"`
+	Added    []string `json:"added"`
+	Linked   bool     `json:"linked,omitempty"`
+}
+
+func newBackup() *Backup {
+	return &Backup{ID: time.Now().Format(BackupIDLayout)}
+}
+
+/*
+ * Creates the directory of the backup the first time something is backed up, refusing
+ * one that already exists so a run never overwrites another run's restore point.
+ */
+func (backup *Backup) create() error {
+	if backup.created {
+		return nil
+	}
+
+	if err := os.MkdirAll(getBackupsDir(), 0755); err != nil {
+		return err
+	}
+	if err := os.Mkdir(backup.dir(), 0755); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("backup %s already exists", backup.ID)
+		}
+		return err
+	}
+
+	backup.created = true
+
+	return nil
+}
+
+func (backup *Backup) dir() string {
+	return path.Join(getBackupsDir(), backup.ID)
+}
+
+/*
+ * Snapshots the files of a config that a downstream copy into `root` is about to
+ * overwrite or delete, as described by `summary`, and records the entry in backup.json.
+ *
+ * Nothing is written when the copy would not overwrite or add anything.
+ */
+func backupFiles(backup *Backup, configName string, root string, summary ChangeSummary) error {
+	// NOTE: a deleted file is restored like an overwritten one, by copying it back
+	overwritten := slices.Concat(summary.Modified, summary.Deleted)
+	if len(overwritten) == 0 && len(summary.Added) == 0 {
+		return nil
+	}
+
+	if err := backup.create(); err != nil {
+		return err
+	}
+
+	for _, name := range overwritten {
+		if err := copyFile(path.Join(root, name), path.Join(backup.dir(), configName, name)); err != nil {
+			return fmt.Errorf("backing up [%s]: %w", path.Join(root, name), err)
+		}
+	}
+
+	backup.Entries = append(backup.Entries, BackupEntry{
+		Config:   configName,
+		Root:     root,
+		Modified: overwritten,
+		Added:    summary.Added,
+	})
+
+	return saveBackup(backup)
+}
+
+/*
+ * Moves the file or directory at a symlinked config's install path into the backup,
+ * so it can be replaced with a link, and records the entry in backup.json.
+ */
+func backupReplaced(backup *Backup, configName string, installPath string) error {
+	if err := backup.create(); err != nil {
+		return err
+	}
+
+	if err := moveAll(installPath, path.Join(backup.dir(), configName, path.Base(installPath))); err != nil {
+		return err
+	}
+
+	backup.Entries = append(backup.Entries, BackupEntry{
+		Config: configName,
+		Root:   installPath,
+		Linked: true,
+	})
+
+	return saveBackup(backup)
+}
+
+func getBackupsDir() string {
+	return path.Join(getStateDir(), "backups")
+}
+
+/*
+ * Returns every backup, oldest first.
+ */
+func listBackups() ([]Backup, error) {
+	dirEntries, err := os.ReadDir(getBackupsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+
+		backup, err := loadBackup(dirEntry.Name())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping unreadable backup [%s]: %v\n", dirEntry.Name(), err)
+			continue
+		}
+
+		backups = append(backups, backup)
+	}
+
+	return backups, nil
+}
+
+func loadBackup(id string) (Backup, error) {
+	var backup Backup
+
+	data, err := os.ReadFile(path.Join(getBackupsDir(), id, "backup.json"))
+	if err != nil {
+		return backup, err
+	}
+
+	err = json.Unmarshal(data, &backup)
+
+	return backup, err
+}
+
+/*
+ * Prints every backup with the configs it contains.
+ */
+func printBackups(backups []Backup) {
+	if len(backups) == 0 {
+		fmt.Printf("No backups in [%s]\n", getBackupsDir())
+		return
+	}
+
+	for _, backup := range backups {
+		fmt.Printf("%s\n", backup.ID)
+		for _, entry := range backup.Entries {
+			if entry.Linked {
+				fmt.Printf("  %s: [%s] replaced with a link\n", entry.Config, entry.Root)
+				continue
+			}
+			fmt.Printf("  %s: %d overwritten, %d added in [%s]\n", entry.Config, len(entry.Modified), len(entry.Added), entry.Root)
+		}
+	}
+}
+
+/*
+ * Rolls back the provided backup: snapshotted files are copied back to where they
+ * were, and files the run added are removed.
+ *
+ * When `configNames` is not empty, only those configs are rolled back.
+ */
+func restoreBackup(backup Backup, configNames []string) error {
+	var errs []error
+	restored := 0
+
+	for _, entry := range backup.Entries {
+		if len(configNames) > 0 && !slices.Contains(configNames, entry.Config) {
+			continue
+		}
+
+		if entry.Linked {
+			if err := restoreReplaced(backup, entry); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", entry.Config, err))
+			} else {
+				fmt.Printf("Restored %s from backup %s\n", entry.Config, backup.ID)
+			}
+			restored++
+			continue
+		}
+
+		for _, name := range entry.Modified {
+			src := path.Join(getBackupsDir(), backup.ID, entry.Config, name)
+			if err := copyFile(src, path.Join(entry.Root, name)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: restoring [%s]: %w", entry.Config, name, err))
+			}
+		}
+
+		for _, name := range entry.Added {
+			if err := os.Remove(path.Join(entry.Root, name)); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("%s: removing [%s]: %w", entry.Config, name, err))
+			}
+		}
+
+		fmt.Printf("Restored %s from backup %s\n", entry.Config, backup.ID)
+		restored++
+	}
+
+	if restored == 0 && len(errs) == 0 {
+		return fmt.Errorf("backup %s does not contain any of %v", backup.ID, configNames)
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+ * Puts back what a symlinked config's link replaced. Only a link is removed to make
+ * room, so anything the user put there since is never deleted.
+ */
+func restoreReplaced(backup Backup, entry BackupEntry) error {
+	state, err := getLinkState(entry.Root)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case state.target != "":
+		if err := os.Remove(entry.Root); err != nil {
+			return fmt.Errorf("removing the link [%s]: %w", entry.Root, err)
+		}
+	case state.exists:
+		return fmt.Errorf("[%s] is no longer a link; move it away to restore it", entry.Root)
+	}
+
+	return moveAll(path.Join(getBackupsDir(), backup.ID, entry.Config, path.Base(entry.Root)), entry.Root)
+}
+
+/*
+ * `configpp restore` lists backups.
+ * `configpp restore <id|latest> [config...]` rolls back a backup, fully or per config.
+ */
+func runRestore(args []string) error {
+	backups, err := listBackups()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		printBackups(backups)
+		return nil
+	}
+
+	id := args[0]
+	if id == "latest" {
+		if len(backups) == 0 {
+			return fmt.Errorf("there are no backups in [%s]", getBackupsDir())
+		}
+		id = backups[len(backups)-1].ID
+	}
+
+	backup, err := loadBackup(id)
+	if err != nil {
+		return fmt.Errorf("loading backup %s: %w", id, err)
+	}
+
+	return restoreBackup(backup, args[1:])
+}
+
+func saveBackup(backup *Backup) error {
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(backup.dir(), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path.Join(backup.dir(), "backup.json"), data, 0644)
+}
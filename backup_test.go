@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	root, err := os.MkdirTemp("", "backup_root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// A local file a downstream copy is about to overwrite and one it is about to add
+	executeCommand(root, "mkdir", "-p", "nvim")
+	executeCommand(root, "bash", "-c", "echo local > nvim/init.lua")
+
+	backup := newBackup()
+	summary := ChangeSummary{Modified: []string{"nvim/init.lua"}, Added: []string{"nvim/new.lua"}}
+	if err := backupFiles(backup, "nvim", root, summary); err != nil {
+		t.Fatalf("Unexpected error backing up files: %v", err)
+	}
+
+	// Simulate the downstream copy
+	executeCommand(root, "bash", "-c", "echo repo > nvim/init.lua && touch nvim/new.lua")
+
+	backups, err := listBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected 1 backup; got %d; err: %v", len(backups), err)
+	}
+
+	// Sad path - the backup does not contain the config
+	if err := restoreBackup(backups[0], []string{"zellij"}); err == nil {
+		t.Errorf("Expected an error restoring a config missing from the backup")
+	}
+
+	if err := runRestore([]string{"latest", "nvim"}); err != nil {
+		t.Fatalf("Unexpected error restoring the latest backup: %v", err)
+	}
+
+	content, err := os.ReadFile(root + "/nvim/init.lua")
+	if err != nil || string(content) != "local\n" {
+		t.Errorf("Expected the overwritten file to be restored; content: %q; err: %v", content, err)
+	}
+
+	if _, err := os.Stat(root + "/nvim/new.lua"); !os.IsNotExist(err) {
+		t.Errorf("Expected the added file to be removed")
+	}
+
+	// Sad path - another run's backup is never overwritten
+	other := &Backup{ID: backup.ID}
+	if err := backupFiles(other, "nvim", root, summary); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected an error reusing the ID of an existing backup; err: %v", err)
+	}
+	if loaded, err := loadBackup(backup.ID); err != nil || len(loaded.Entries) != 1 {
+		t.Errorf("Expected the existing backup to be unchanged; entries: %+v; err: %v", loaded.Entries, err)
+	}
+}
+
+func TestBackupFilesWithoutChanges(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	backup := newBackup()
+	if err := backupFiles(backup, "nvim", "/does-not-exist", ChangeSummary{}); err != nil {
+		t.Errorf("Unexpected error backing up nothing: %v", err)
+	}
+
+	if _, err := os.Stat(backup.dir()); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup directory when nothing would be overwritten")
+	}
+}
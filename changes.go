@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ * ChangeSummary
+ *
+ * The files a sync of a single config adds, modifies, or deletes at its destination.
+ * Paths are relative to `Root`, the directory the changes were made in. `Templated`
+ * files were edited after being rendered from a template, and were not copied over it.
+ * `deletes` is set by syncs that remove files, such as `syncFonts`; copies leave files
+ * only present at the destination alone (see `syncPath`), so they report no deletions.
+ */
+type ChangeSummary struct {
+	config    string
+	deletes   bool
+	Root      string
+	Added     []string
+	Modified  []string
+	Deleted   []string
+	Templated []string
+	Unchanged int
+}
+
+func (summary ChangeSummary) isEmpty() bool {
+	return len(summary.Added) == 0 && len(summary.Modified) == 0 && len(summary.Deleted) == 0
+}
+
+/*
+ * Prints the counts and files of a single config's summary.
+ */
+func printChangeSummary(summary ChangeSummary) {
+	counts := fmt.Sprintf("%d added, %d modified", len(summary.Added), len(summary.Modified))
+	if summary.deletes {
+		counts += fmt.Sprintf(", %d deleted", len(summary.Deleted))
+	}
+
+	fmt.Printf("\n%s: %s, %d unchanged\n", summary.config, counts, summary.Unchanged)
+	if summary.isEmpty() {
+		fmt.Printf("  (no changes)\n")
+	}
+
+	for _, name := range summary.Added {
+		fmt.Printf("  + %s\n", name)
+	}
+	for _, name := range summary.Modified {
+		fmt.Printf("  ~ %s\n", name)
+	}
+	for _, name := range summary.Deleted {
+		fmt.Printf("  - %s\n", name)
+	}
+	for _, name := range summary.Templated {
+		fmt.Printf("  ! %s was rendered from a template; edit the template in the repo instead\n", name)
+	}
+}
+
+/*
+ * Prints the configs a downstream sync skipped, or installed with `-force`, because the
+ * binary they belong to is not installed.
+ */
+func printMissingBinaries(skipped []Config, forced []Config) {
+	if len(skipped) > 0 {
+		fmt.Printf("\nSkipped %d config(s) whose binary is not installed; rerun with -force to install them anyway\n", len(skipped))
+		for _, config := range skipped {
+			fmt.Printf("  %s (%s)\n", config.name, config.binary)
+		}
+	}
+
+	if len(forced) > 0 {
+		fmt.Printf("\nInstalled %d config(s) with -force although their binary is not installed\n", len(forced))
+		for _, config := range forced {
+			fmt.Printf("  %s (%s)\n", config.name, config.binary)
+		}
+	}
+}
+
+/*
+ * Prints the per-config summary of a dry run.
+ */
+func printChangeSummaries(summaries []ChangeSummary) {
+	fmt.Printf("-----------------------------------\n")
+	fmt.Printf("\nDry run summary (nothing was changed)\n")
+
+	for _, summary := range summaries {
+		printChangeSummary(summary)
+	}
+}
+
+/*
+ * Prints the command a dry run would have executed from the provided directory.
+ */
+func reportDryRun(dir string, command string, arg ...string) {
+	fmt.Printf("\n[dry-run] Would run `%s %s` in %s\n", command, strings.Join(arg, " "), dir)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+/*
+ * Command
+ *
+ * A `configpp <name>` subcommand. `args` describes its positional arguments in the usage
+ * line, and `run` receives the positional arguments left after parsing `flags`.
+ * `shared` names the top-level flags the command accepts (see `shareFlags`), and `setup`
+ * registers flags only the command has.
+ */
+type Command struct {
+	name    string
+	args    string
+	summary string
+	shared  []string
+	setup   func(flags *flag.FlagSet)
+	flags   *flag.FlagSet
+	run     func(args []string) error
+}
+
+/*
+ * Returns every subcommand with a fresh set of flags.
+ *
+ * The flags shared between commands are the top-level flags themselves (see
+ * `shareFlags`), so `configpp -m x pull` and `configpp pull -m x` are the same.
+ */
+func newCommands() []Command {
+	var addOptions AddOptions
+	var commitOptions CommitOptions
+	var pullOptions PullOptions
+
+	commands := []Command{
+		{
+			name:    "pull",
+			args:    "[config...]",
+			summary: "Pull " + ConfigsSrc + " and copy the configs to their install paths (the default command)",
+			shared:  []string{"m", "profile", "only", "skip", "dry-run", "resolve", "remote", "branch", "force"},
+			setup: func(flags *flag.FlagSet) {
+				flags.BoolVar(&pullOptions.purgeNvim, "purge-nvim", false, "Remove nvim's data directory (~/.local/share/nvim) before restoring its plugins")
+			},
+			run: func(args []string) error { return runPull(args, pullOptions) },
+		},
+		{
+			name:    "push",
+			args:    "[config...]",
+			summary: "Copy the installed configs into " + ConfigsSrc + ", commit the ones you pick, and push (same as -u)",
+			shared:  []string{"m", "profile", "only", "skip", "dry-run", "resolve", "remote", "branch"},
+			setup: func(flags *flag.FlagSet) {
+				flags.BoolVar(&commitOptions.yes, "y", false, "Commit every changed config without prompting")
+				flags.StringVar(&commitOptions.message, "message", "", "Commit message; defaults to \"<configs>: update from <hostname>\"")
+			},
+			run: func(args []string) error { return runPush(args, commitOptions) },
+		},
+		{
+			name:    "status",
+			args:    "[config...]",
+			summary: "Show the state of " + ConfigsSrc + " and when each config was last synced",
+			shared:  []string{"m", "profile", "only", "skip", "remote", "branch"},
+			run:     runStatus,
+		},
+		{
+			name:    "diff",
+			args:    "[config...]",
+			summary: "Print a unified diff of the repo copy (-) against the installed copy (+)",
+			shared:  []string{"m", "profile", "only", "skip"},
+			run: func(args []string) error {
+				configs, err := loadConfigs(args)
+				if err != nil {
+					return err
+				}
+				return runDiff(configs)
+			},
+		},
+		{
+			name:    "list",
+			args:    "[config...]",
+			summary: "List the configs in the manifest and where they are installed on this machine",
+			shared:  []string{"m", "profile", "only", "skip"},
+			run:     runList,
+		},
+		{
+			name:    "add",
+			args:    "<name> <install path>",
+			summary: "Copy an installed config into " + ConfigsSrc + " and add it to the manifest",
+			shared:  []string{"m", "dry-run"},
+			setup: func(flags *flag.FlagSet) {
+				flags.StringVar(&addOptions.repo, "repo", "", "Path of the config in "+ConfigsSrc+"; defaults to <name>, or <name>/<file> for a single file")
+				flags.StringVar(&addOptions.key, "key", DefaultInstallKey, "Install key the path applies to, such as \"linux\" or \"host:<hostname>\"")
+			},
+			run: func(args []string) error { return runAdd(args, addOptions) },
+		},
+		{
+			name:    "secrets",
+			args:    "edit <file>",
+			summary: "Decrypt a secret in " + ConfigsSrc + " into a temporary file, edit it, and encrypt it back",
+			run:     runSecrets,
+		},
+		{
+			name:    "restore",
+			args:    "[<id|latest> [config...]]",
+			summary: "List backups, or roll back the local files a pull overwrote",
+			run:     runRestore,
+		},
+		{
+			name:    "doctor",
+			summary: "Check that git, " + ConfigsSrc + ", the manifest, and the state directory are usable",
+			shared:  []string{"m", "profile", "remote", "branch"},
+			run:     func(args []string) error { return runDoctor(os.Stdout) },
+		},
+	}
+
+	for i := range commands {
+		command := &commands[i]
+
+		// NOTE: like the top-level flags, invalid flags print the usage and exit
+		command.flags = flag.NewFlagSet(command.name, flag.ExitOnError)
+		command.flags.Usage = func() { printCommandUsage(command.flags.Output(), *command) }
+
+		shareFlags(command.flags, command.shared...)
+		if command.setup != nil {
+			command.setup(command.flags)
+		}
+	}
+
+	return commands
+}
+
+/*
+ * Returns the subcommand with the provided name.
+ */
+func findCommand(commands []Command, name string) (Command, bool) {
+	for _, command := range commands {
+		if command.name == name {
+			return command, true
+		}
+	}
+
+	return Command{}, false
+}
+
+func printCommandUsage(out io.Writer, command Command) {
+	fmt.Fprintf(out, "Usage: configpp %s [flags] %s\n\n%s\n", command.name, command.args, command.summary)
+
+	hasFlags := false
+	command.flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintf(out, "\nFlags:\n")
+		command.flags.PrintDefaults()
+	}
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintf(out, "Usage: configpp [flags] <command> [flags] [args]\n\nCommands:\n")
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, command := range newCommands() {
+		fmt.Fprintf(writer, "  %s\t%s\n", command.name, command.summary)
+	}
+	writer.Flush()
+
+	fmt.Fprintf(out, "\nWithout a command, configpp pulls, or pushes with -u; config names may still be passed, i.e. `configpp nvim` or `configpp -u nvim`.\n")
+	fmt.Fprintf(out, "Run `configpp help <command>` for a command's flags.\n\nFlags:\n")
+	flag.CommandLine.SetOutput(out)
+	flag.PrintDefaults()
+}
+
+/*
+ * Runs the subcommand named by the first argument.
+ *
+ * Anything else keeps the behavior of configpp before it had subcommands: no command
+ * pulls, `-u` pushes, and the arguments are config names.
+ */
+func runCLI(args []string) error {
+	commands := newCommands()
+
+	if len(args) > 0 && args[0] == "help" {
+		if len(args) == 1 {
+			printUsage(os.Stdout)
+			return nil
+		}
+
+		command, ok := findCommand(commands, args[1])
+		if !ok {
+			return fmt.Errorf("unknown command %q; run `configpp help` for the list of commands", args[1])
+		}
+		printCommandUsage(os.Stdout, command)
+
+		return nil
+	}
+
+	if len(args) > 0 {
+		if command, ok := findCommand(commands, args[0]); ok {
+			if *FlagUpstream && command.name != "push" {
+				return fmt.Errorf("-u cannot be combined with `%s`", command.name)
+			}
+
+			command.flags.Parse(args[1:])
+
+			return command.run(command.flags.Args())
+		}
+	}
+
+	if *FlagUpstream {
+		return runPush(args, CommitOptions{})
+	}
+
+	return runPull(args, PullOptions{})
+}
+
+/*
+ * `configpp list [config...]` prints this machine's profile, then each config, its repo
+ * path, and its install path on this machine.
+ */
+func runList(names []string) error {
+	configs, err := loadConfigs(names)
+	if err != nil {
+		return err
+	}
+
+	// NOTE: the profile was already resolved by `loadConfigs`, so this cannot fail
+	if profile, _, _ := loadProfile(*FlagManifest, *FlagProfile); profile != "" {
+		fmt.Printf("Profile: %s\n\n", profile)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "NAME\tTYPE\tREPO\tINSTALL\n")
+
+	for _, config := range configs {
+		configType := "file"
+		if config.dir {
+			configType = "dir"
+		}
+		if config.mode == ModeSymlink {
+			configType += " (symlink)"
+		}
+		if config.kind == TypeNvim || config.kind == TypeFonts {
+			configType += " (" + config.kind + ")"
+		}
+
+		installPath, err := getOSSpecificDestionationPath(config)
+		if err != nil {
+			installPath = "(not installed on this machine)"
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", config.name, configType, config.localDotfilesRepoPath, installPath)
+	}
+
+	return writer.Flush()
+}
+
+/*
+ * Registers top-level flags on a subcommand's flag set. They share the top-level
+ * flag's value, so the flag can be passed before or after the command name.
+ */
+func shareFlags(flags *flag.FlagSet, names ...string) {
+	for _, name := range names {
+		shared := flag.Lookup(name)
+		if shared == nil {
+			panic("no top-level flag named " + name)
+		}
+
+		flags.Var(shared.Value, shared.Name, shared.Usage)
+	}
+}
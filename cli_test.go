@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+/*
+ * Points ConfigsSrc, the manifest, and the state directory at temporary directories
+ * for the rest of the test, and returns the new ConfigsSrc.
+ */
+func configsSandbox(t *testing.T, configsSrc string) string {
+	if configsSrc == "" {
+		configsSrc = t.TempDir()
+	}
+
+	previousSrc, previousManifest := ConfigsSrc, *FlagManifest
+	t.Cleanup(func() {
+		ConfigsSrc = previousSrc
+		*FlagManifest = previousManifest
+	})
+
+	ConfigsSrc = configsSrc
+	*FlagManifest = path.Join(configsSrc, ManifestFile)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	return configsSrc
+}
+
+func TestRunCLI(t *testing.T) {
+	configsSrc := configsSandbox(t, "")
+	os.WriteFile(path.Join(configsSrc, ManifestFile), []byte(`{"configs": [{"name": "nvim", "dir": true, "repo": "nvim", "install": "/tmp/nvim"}]}`), 0644)
+
+	// Happy path
+	for _, args := range [][]string{{"help"}, {"help", "pull"}, {"list"}, {"list", "nvim"}} {
+		if err := runCLI(args); err != nil {
+			t.Errorf("Unexpected error running %v: %v", args, err)
+		}
+	}
+
+	// Sad path - unknown commands and configs
+	for _, args := range [][]string{{"help", "fetch"}, {"list", "nvm"}} {
+		if err := runCLI(args); err == nil {
+			t.Errorf("Expected an error running %v", args)
+		}
+	}
+
+	// Sad path - -u only aliases push
+	*FlagUpstream = true
+	defer func() { *FlagUpstream = false }()
+
+	if err := runCLI([]string{"diff"}); err == nil {
+		t.Errorf("Expected an error combining -u with diff")
+	}
+}
+
+func TestShareFlags(t *testing.T) {
+	defer func() { *FlagOnly = "" }()
+
+	command, ok := findCommand(newCommands(), "status")
+	if !ok {
+		t.Fatalf("Expected a status command")
+	}
+
+	command.flags.Parse([]string{"-only", "nvim", "zellij"})
+
+	if *FlagOnly != "nvim" || len(command.flags.Args()) != 1 {
+		t.Errorf("Expected -only to set the top-level flag; only: %q; args: %v", *FlagOnly, command.flags.Args())
+	}
+
+	// Commands only accept the flags that apply to them
+	command, _ = findCommand(newCommands(), "status")
+	if command.flags.Lookup("resolve") != nil {
+		t.Errorf("Expected status not to accept -resolve")
+	}
+}
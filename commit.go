@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+ * CommitOptions
+ *
+ * The flags of `configpp push`. `yes` commits every changed config without prompting,
+ * and `message` replaces the generated commit message.
+ */
+type CommitOptions struct {
+	yes     bool
+	message string
+}
+
+/*
+ * Asks a yes or no question, defaulting to yes when the answer is empty or there is
+ * no more input.
+ */
+func confirm(reader *bufio.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [Y/n] ", question)
+
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+/*
+ * Commits the configs an upstream copy changed in the repo:
+ * 1. Stages each changed config and shows its staged diff
+ * 2. Asks whether to include it, unstaging it when it is left out
+ * 3. Asks for a commit message, defaulting to "<configs>: update from <hostname>"
+ * 4. Commits only the included configs
+ *
+ * Prompts are read from `in`, and running out of input accepts the defaults, so a
+ * non-interactive run commits everything with the generated message. Returns whether
+ * anything was committed.
+ */
+func commitConfigs(git Git, configs []Config, options CommitOptions, in io.Reader, out io.Writer) (bool, error) {
+	status, err := git.status()
+	if err != nil {
+		return false, err
+	}
+
+	reader := bufio.NewReader(in)
+
+	var names, paths []string
+	for _, config := range configs {
+		rel, ok := strings.CutPrefix(config.localDotfilesRepoPath, ConfigsSrc+"/")
+		if !ok || countUncommitted(status, config) == 0 {
+			continue
+		}
+
+		if _, stderr := git.add(rel); stderr != nil {
+			return false, fmt.Errorf("staging [%s]: %w", config.name, stderr)
+		}
+
+		diff, stderr := git.diff(rel)
+		if stderr != nil {
+			return false, fmt.Errorf("diffing [%s]: %w", config.name, stderr)
+		}
+
+		fmt.Fprintf(out, "-----------------------------------\n")
+		fmt.Fprintf(out, "\nStaged changes to [%s]\n\n%s\n", config.name, diff)
+
+		if !options.yes && !confirm(reader, out, fmt.Sprintf("Include [%s] in the commit?", config.name)) {
+			if _, stderr := git.reset(rel); stderr != nil {
+				return false, fmt.Errorf("unstaging [%s]: %w", config.name, stderr)
+			}
+			continue
+		}
+
+		names = append(names, config.name)
+		paths = append(paths, rel)
+	}
+
+	if len(names) == 0 {
+		fmt.Fprintf(out, "\nNothing to commit in [%s]\n", git.dir)
+		return false, nil
+	}
+
+	message := options.message
+	generated := fmt.Sprintf("%s: update from %s", strings.Join(names, ", "), getHostname())
+	if message == "" && !options.yes {
+		fmt.Fprintf(out, "\nCommit message (empty for %q): ", generated)
+		answer, _ := reader.ReadString('\n')
+		message = strings.TrimSpace(answer)
+	}
+	if message == "" {
+		message = generated
+	}
+
+	stdout, stderr := git.commit(message, paths...)
+	if stderr != nil {
+		return false, fmt.Errorf("committing: %w", stderr)
+	}
+
+	fmt.Fprintf(out, "\n%s", stdout)
+
+	return true, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCommitConfigs(t *testing.T) {
+	gitCreateSandbox(func(dir string) {
+		configsSandbox(t, dir)
+		git := newGit(dir)
+
+		nvim := Config{name: "nvim", dir: true, localDotfilesRepoPath: path.Join(dir, "nvim")}
+		zellij := Config{name: "zellij", dir: true, localDotfilesRepoPath: path.Join(dir, "zellij")}
+		vim := Config{name: "vim", localDotfilesRepoPath: path.Join(dir, "vim", ".vimrc")}
+		configs := []Config{nvim, zellij, vim}
+
+		executeCommand(dir, "bash", "-c", "mkdir nvim zellij && echo nvim > nvim/init.lua && echo zellij > zellij/config.kdl")
+
+		// Include nvim, leave zellij out, and accept the generated message
+		var out bytes.Buffer
+		committed, err := commitConfigs(git, configs, CommitOptions{}, strings.NewReader("y\nn\n\n"), &out)
+		if err != nil || !committed {
+			t.Fatalf("Expected a commit; err: %v\n%s", err, out.String())
+		}
+		if !strings.Contains(out.String(), "nvim/init.lua") || !strings.Contains(out.String(), "+nvim") {
+			t.Errorf("Expected the staged diff of nvim:\n%s", out.String())
+		}
+
+		subject, _ := git.output("log", "-1", "--format=%s")
+		if strings.TrimSpace(string(subject)) != "nvim: update from "+getHostname() {
+			t.Errorf("Commit message (%s) not as expected", subject)
+		}
+
+		status, _ := git.status()
+		if strings.Join(status.Untracked, ",") != "zellij/" || len(status.Staged) != 0 {
+			t.Errorf("Expected zellij to be left out and unstaged; status: %+v", status)
+		}
+
+		// Without prompting
+		out.Reset()
+		committed, err = commitConfigs(git, configs, CommitOptions{yes: true, message: "Update zellij"}, strings.NewReader(""), &out)
+		if err != nil || !committed {
+			t.Fatalf("Expected a commit; err: %v\n%s", err, out.String())
+		}
+
+		subject, _ = git.output("log", "-1", "--format=%s")
+		if strings.TrimSpace(string(subject)) != "Update zellij" {
+			t.Errorf("Commit message (%s) not as expected", subject)
+		}
+
+		// Sad path - nothing changed
+		out.Reset()
+		committed, err = commitConfigs(git, configs, CommitOptions{}, strings.NewReader(""), &out)
+		if err != nil || committed {
+			t.Errorf("Expected nothing to be committed; committed: %v; err: %v", committed, err)
+		}
+	})
+}
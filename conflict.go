@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// Values accepted by `-resolve`
+const (
+	ResolveLocal  = "local"
+	ResolveRemote = "remote"
+	ResolveMerge  = "merge"
+)
+
+/*
+ * Conflict
+ *
+ * A file that changed in both the repo (`repoPath`) and the install path (`installPath`)
+ * since the last sync, to different content. `rel` is relative to the config's root.
+ */
+type Conflict struct {
+	config      string
+	rel         string
+	repoPath    string
+	installPath string
+}
+
+/*
+ * Returns the files of a config that changed on both sides since the hashes recorded
+ * in `state` by the last sync.
+ *
+ * Files without a recorded hash are never conflicts because there is no way to tell
+ * which side changed, which also means the first sync of a config cannot conflict.
+ */
+func detectConflicts(state SyncState, config Config) ([]Conflict, error) {
+	base := state.config(config.name).Files
+	if len(base) == 0 {
+		return nil, nil
+	}
+
+	repoHashes, installHashes, err := hashConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	installPath, err := getOSSpecificDestionationPath(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []Conflict
+	for _, rel := range sortedKeys(base) {
+		baseHash := base[rel]
+		repoHash := repoHashes[rel]
+		installHash := installHashes[rel]
+
+		if repoHash != baseHash && installHash != baseHash && repoHash != installHash {
+			repoPath := path.Join(config.localDotfilesRepoPath, rel)
+			if templatePath, ok := findTemplate(repoPath); ok {
+				repoPath = templatePath
+			} else if secretPath, ok := findSecret(repoPath); ok {
+				repoPath = secretPath
+			}
+
+			conflicts = append(conflicts, Conflict{
+				config:      config.name,
+				rel:         rel,
+				repoPath:    repoPath,
+				installPath: path.Join(installPath, rel),
+			})
+		}
+	}
+
+	return conflicts, nil
+}
+
+/*
+ * Escapes glob characters so a relative path can be used as an exact, anchored pattern.
+ */
+func escapeGlob(rel string) string {
+	var builder strings.Builder
+	for _, char := range rel {
+		if strings.ContainsRune(`*?[]\!#`, char) {
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(char)
+	}
+
+	return builder.String()
+}
+
+/*
+ * Returns the merge tool command, $CONFIGPP_MERGETOOL, defaulting to vimdiff.
+ */
+func getMergeTool() []string {
+	tool := strings.Fields(os.Getenv("CONFIGPP_MERGETOOL"))
+	if len(tool) == 0 {
+		return []string{"vimdiff"}
+	}
+
+	return tool
+}
+
+/*
+ * Returns the SHA-256 of every synced file in the repo and installed copies of a config.
+ * Templates are hashed as what they render (see `hashRepoTree`), and a fonts config only
+ * hashes its own fonts (see `ownFonts`).
+ */
+func hashConfig(config Config) (map[string]string, map[string]string, error) {
+	installPath, err := getOSSpecificDestionationPath(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matcher, err := getIgnoreMatcher(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repoHashes, err := hashRepoTree(config, matcher)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	installHashes, err := hashTree(installPath, matcher)
+	if config.kind == TypeFonts {
+		repoHashes, installHashes = ownFonts(repoHashes, installHashes)
+	}
+
+	return repoHashes, installHashes, err
+}
+
+/*
+ * Opens the merge tool ($CONFIGPP_MERGETOOL, defaulting to vimdiff) with the installed
+ * copy on the left and the repo copy on the right. The installed copy is expected to
+ * hold the merged result when the tool exits, and is then copied into the repo so both
+ * sides match.
+ */
+func mergeConflict(conflict Conflict) error {
+	// Copying the merged result into the repo would replace the template with its output
+	if strings.HasSuffix(conflict.repoPath, TemplateSuffix) {
+		return fmt.Errorf("[%s] is rendered from the template [%s]; edit the template and resolve with `-resolve remote`", conflict.installPath, conflict.repoPath)
+	}
+	// Merging against the ciphertext is meaningless, and would copy the plaintext into the repo
+	if isSecret(conflict.repoPath) {
+		return fmt.Errorf("[%s] is decrypted from the secret [%s]; edit it with `configpp secrets edit` and resolve with `-resolve remote`", conflict.installPath, conflict.repoPath)
+	}
+
+	tool := getMergeTool()
+
+	cmd := exec.Command(tool[0], append(tool[1:], conflict.installPath, conflict.repoPath)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("merge tool `%s` failed: %w", strings.Join(tool, " "), err)
+	}
+
+	_, err := syncPath(conflict.installPath, conflict.repoPath, SyncOptions{Checksum: true})
+
+	return err
+}
+
+/*
+ * Prints every conflict and how to resolve them.
+ */
+func printConflicts(conflicts []Conflict) {
+	fmt.Fprintf(os.Stderr, "-----------------------------------\n")
+	fmt.Fprintf(os.Stderr, "\nStopping: %d file(s) changed in both the repo and the install path since the last sync\n\n", len(conflicts))
+
+	for _, conflict := range conflicts {
+		fmt.Fprintf(os.Stderr, "CONFLICT %s: [%s] and [%s]\n", conflict.config, conflict.repoPath, conflict.installPath)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nRerun with `-resolve local` to keep the installed copies, `-resolve remote` to keep the repo copies, or `-resolve merge` to merge each file\n")
+}
+
+/*
+ * Resolves conflicts and returns, per config, the files relative to its root that the
+ * sync must skip so it does not overwrite the side that won:
+ *
+ * - local keeps the installed copy, so a downstream sync skips the file
+ * - remote keeps the repo copy, so an upstream sync skips the file
+ * - merge opens the merge tool, after which both sides match
+ */
+func resolveConflicts(conflicts []Conflict, resolution string, upstream bool) (map[string][]string, error) {
+	skip := map[string][]string{}
+
+	for _, conflict := range conflicts {
+		switch resolution {
+		case ResolveLocal:
+			if !upstream {
+				skip[conflict.config] = append(skip[conflict.config], conflict.rel)
+			}
+		case ResolveRemote:
+			if upstream {
+				skip[conflict.config] = append(skip[conflict.config], conflict.rel)
+			}
+		case ResolveMerge:
+			fmt.Printf("\nMerging %s: save the merged result on the left ([%s])\n", conflict.config, conflict.installPath)
+			if err := mergeConflict(conflict); err != nil {
+				return nil, fmt.Errorf("%s: %w", conflict.config, err)
+			}
+		default:
+			return nil, validateResolution(resolution)
+		}
+	}
+
+	return skip, nil
+}
+
+/*
+ * Returns an error unless the resolution is empty or one `resolveConflicts` knows, so a
+ * typo fails before anything is copied instead of when a conflict shows up.
+ */
+func validateResolution(resolution string) error {
+	switch resolution {
+	case "", ResolveLocal, ResolveRemote, ResolveMerge:
+		return nil
+	default:
+		return fmt.Errorf("unknown -resolve value %q; expected %s, %s, or %s", resolution, ResolveLocal, ResolveRemote, ResolveMerge)
+	}
+}
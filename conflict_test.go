@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+/*
+ * Creates a repo and install directory holding the same init.lua, records them as
+ * synced, and calls the callback with a config pointing at both.
+ */
+func conflictSandbox(t *testing.T, callback func(config Config, state SyncState)) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	repoDir, err := os.MkdirTemp("", "conflict_repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repoDir)
+
+	installDir, err := os.MkdirTemp("", "conflict_install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(installDir)
+
+	executeCommand(repoDir, "bash", "-c", "echo base > init.lua && echo base > other.lua")
+	executeCommand(installDir, "bash", "-c", "echo base > init.lua && echo base > other.lua")
+
+	config := Config{
+		name:                  "nvim",
+		dir:                   true,
+		localInstallPath:      InstallPaths{DefaultInstallKey: installDir},
+		localDotfilesRepoPath: repoDir,
+	}
+
+	state, err := loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recordSync(&state, config, DirectionDownstream, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	callback(config, state)
+}
+
+func TestDetectConflicts(t *testing.T) {
+	// Happy path - nothing has been synced yet, so nothing can conflict
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	state, _ := loadState()
+	conflicts, err := detectConflicts(state, Nvim)
+	if err != nil || len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts without sync state; conflicts: %v; err: %v", conflicts, err)
+	}
+
+	conflictSandbox(t, func(config Config, state SyncState) {
+		// Only one side changed
+		executeCommand(config.localDotfilesRepoPath, "bash", "-c", "echo repo > other.lua")
+
+		conflicts, err := detectConflicts(state, config)
+		if err != nil || len(conflicts) != 0 {
+			t.Errorf("Expected no conflicts when one side changed; conflicts: %v; err: %v", conflicts, err)
+		}
+
+		// Both sides changed to the same content
+		executeCommand(config.localInstallPath[DefaultInstallKey], "bash", "-c", "echo repo > other.lua")
+
+		conflicts, err = detectConflicts(state, config)
+		if err != nil || len(conflicts) != 0 {
+			t.Errorf("Expected no conflicts when both sides match; conflicts: %v; err: %v", conflicts, err)
+		}
+
+		// Sad path - both sides changed differently
+		executeCommand(config.localDotfilesRepoPath, "bash", "-c", "echo repo > init.lua")
+		executeCommand(config.localInstallPath[DefaultInstallKey], "bash", "-c", "echo local > init.lua")
+
+		conflicts, err = detectConflicts(state, config)
+		if err != nil || len(conflicts) != 1 || conflicts[0].rel != "init.lua" {
+			t.Errorf("Expected init.lua to conflict; conflicts: %v; err: %v", conflicts, err)
+		}
+	})
+}
+
+func TestResolveConflicts(t *testing.T) {
+	conflictSandbox(t, func(config Config, state SyncState) {
+		executeCommand(config.localDotfilesRepoPath, "bash", "-c", "echo repo > init.lua")
+		executeCommand(config.localInstallPath[DefaultInstallKey], "bash", "-c", "echo local > init.lua")
+
+		conflicts, _ := detectConflicts(state, config)
+
+		// Keeping local on a downstream sync skips the file; keeping remote does not
+		skip, err := resolveConflicts(conflicts, ResolveLocal, false)
+		if err != nil || strings.Join(skip["nvim"], ",") != "init.lua" {
+			t.Errorf("Expected init.lua to be skipped; skip: %v; err: %v", skip, err)
+		}
+
+		skip, err = resolveConflicts(conflicts, ResolveRemote, false)
+		if err != nil || len(skip["nvim"]) != 0 {
+			t.Errorf("Expected nothing to be skipped; skip: %v; err: %v", skip, err)
+		}
+
+		// Merging writes the merge tool's result to both sides
+		tool := t.TempDir() + "/mergetool"
+		os.WriteFile(tool, []byte("#!/bin/sh\necho merged > \"$1\"\n"), 0755)
+		t.Setenv("CONFIGPP_MERGETOOL", tool)
+
+		if _, err := resolveConflicts(conflicts, ResolveMerge, false); err != nil {
+			t.Fatalf("Unexpected error merging: %v", err)
+		}
+
+		content, _ := os.ReadFile(config.localDotfilesRepoPath + "/init.lua")
+		if string(content) != "merged\n" {
+			t.Errorf("Expected the repo copy to hold the merged result; content: %q", content)
+		}
+
+		// Sad path - unknown resolution
+		if _, err := resolveConflicts(conflicts, "theirs", false); err == nil {
+			t.Errorf("Expected an error for an unknown resolution")
+		}
+	})
+
+	// Sad path - an unknown resolution stops a run before anything is pulled or copied,
+	// even when nothing conflicts
+	*FlagResolve = "lcoal"
+	defer func() { *FlagResolve = "" }()
+	for _, run := range []func() error{
+		func() error { return runPull(nil, PullOptions{}) },
+		func() error { return runPush(nil, CommitOptions{}) },
+	} {
+		if err := run(); err == nil || !strings.Contains(err.Error(), "unknown -resolve value") {
+			t.Errorf("Expected an error for an unknown resolution; err: %v", err)
+		}
+	}
+}
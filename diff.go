@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Lines of unchanged context around each hunk of a unified diff
+const DiffContext = 3
+
+/*
+ * A single line of an edit script: ' ' for unchanged, '-' for removed from the repo
+ * copy, and '+' for added in the installed copy.
+ */
+type diffOp struct {
+	kind byte
+	line string
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+/*
+ * Returns the files under `root`, keyed by their path relative to `root`, skipping
+ * whatever the matcher excludes the same way `cpConfig` does.
+ *
+ * A root that is a single file is keyed as ".", and a missing root has no files.
+ */
+func collectFiles(root string, matcher IgnoreMatcher) (map[string]string, error) {
+	files := map[string]string{}
+
+	info, err := os.Stat(root)
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		files["."] = root
+		return files, nil
+	}
+
+	// A linked config is walked through its link, which the walk would not follow
+	if root, err = resolveRoot(root); err != nil {
+		return nil, err
+	}
+
+	err = filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if filePath == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if matcher.excluded(rel, entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !entry.IsDir() {
+			files[rel] = filePath
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+/*
+ * Prints a unified diff between the repo copy (`config.localDotfilesRepoPath`) and the
+ * installed copy of a config, followed by the files that only exist on one side.
+ * Templates are compared with the file they render (see `collectRepoFiles`).
+ *
+ * Returns whether the two sides differ.
+ */
+func diffConfig(out io.Writer, config Config) (bool, error) {
+	installPath, err := getOSSpecificDestionationPath(config)
+	if err != nil {
+		return false, err
+	}
+
+	matcher, err := getIgnoreMatcher(config)
+	if err != nil {
+		return false, err
+	}
+
+	repoFiles, err := collectRepoFiles(config, matcher)
+	if err != nil {
+		return false, err
+	}
+	installFiles, err := collectFiles(installPath, matcher)
+	if err != nil {
+		return false, err
+	}
+	if config.kind == TypeFonts {
+		repoFiles, installFiles = ownFonts(repoFiles, installFiles)
+	}
+
+	var repoOnly []string
+	var installOnly []string
+	var changed []string
+	for _, rel := range sortedKeys(repoFiles) {
+		if _, ok := installFiles[rel]; !ok {
+			repoOnly = append(repoOnly, repoFiles[rel])
+			continue
+		}
+
+		same, err := filesEqual(config, repoFiles[rel], installFiles[rel])
+		if err != nil {
+			return false, err
+		}
+		if !same {
+			changed = append(changed, rel)
+		}
+	}
+	for _, rel := range sortedKeys(installFiles) {
+		if _, ok := repoFiles[rel]; !ok {
+			installOnly = append(installOnly, installFiles[rel])
+		}
+	}
+
+	if len(changed) == 0 && len(repoOnly) == 0 && len(installOnly) == 0 {
+		return false, nil
+	}
+
+	fmt.Fprintf(out, "-----------------------------------\n")
+	fmt.Fprintf(out, "%s: repo [%s] vs installed [%s]\n", config.name, config.localDotfilesRepoPath, installPath)
+
+	for _, rel := range changed {
+		if err := writeFileDiff(out, config, repoFiles[rel], installFiles[rel]); err != nil {
+			return true, err
+		}
+	}
+
+	for _, filePath := range repoOnly {
+		fmt.Fprintf(out, "Only in repo: %s\n", filePath)
+	}
+	for _, filePath := range installOnly {
+		fmt.Fprintf(out, "Only installed: %s\n", filePath)
+	}
+
+	return true, nil
+}
+
+/*
+ * Returns the edit script turning `a` into `b` using Myers' O(ND) algorithm, which
+ * produces the same minimal diffs as `diff -u`.
+ *
+ * The linear space variant is used: instead of keeping every round of the search to
+ * walk back along, the middle snake of the shortest path splits the files in two, and
+ * each half is diffed the same way, so large rewritten files fit in memory.
+ */
+func diffLines(a []string, b []string) []diffOp {
+	var ops []diffOp
+
+	// Common lines at either end are never part of an edit
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, diffOp{kind: ' ', line: a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-suffix-1] == b[len(b)-suffix-1] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			ops = append(ops, diffOp{kind: '+', line: line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			ops = append(ops, diffOp{kind: '-', line: line})
+		}
+	default:
+		x, y, u, v := findMiddleSnake(a, b)
+		ops = append(ops, diffLines(a[:x], b[:y])...)
+		for _, line := range a[x:u] {
+			ops = append(ops, diffOp{kind: ' ', line: line})
+		}
+		ops = append(ops, diffLines(a[u:], b[v:])...)
+	}
+
+	for _, line := range common {
+		ops = append(ops, diffOp{kind: ' ', line: line})
+	}
+
+	return ops
+}
+
+/*
+ * Returns whether a repo file installs the same content as the installed file.
+ */
+func filesEqual(config Config, repoPath string, installPath string) (bool, error) {
+	repoContent, err := readRepoFile(config, repoPath)
+	if err != nil {
+		return false, err
+	}
+	installContent, err := os.ReadFile(installPath)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(repoContent, installContent), nil
+}
+
+/*
+ * Returns the middle snake of the shortest edit script turning `a` into `b`, from
+ * (x, y) to (u, v), by searching forward from the start and backward from the end
+ * until the two searches overlap.
+ */
+func findMiddleSnake(a []string, b []string) (int, int, int, int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+
+	// The furthest x reached on each diagonal k = x - y, forward from the start and
+	// backward from the end (counting from the end)
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[k-1+offset] < forward[k+1+offset]) {
+				x = forward[k+1+offset]
+			} else {
+				x = forward[k-1+offset] + 1
+			}
+
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[k+offset] = x
+
+			if odd && delta-k >= -(d-1) && delta-k <= d-1 && x+backward[delta-k+offset] >= n {
+				return startX, startY, x, y
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[k-1+offset] < backward[k+1+offset]) {
+				x = backward[k+1+offset]
+			} else {
+				x = backward[k-1+offset] + 1
+			}
+
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[k+offset] = x
+
+			if !odd && delta-k >= -d && delta-k <= d && x+forward[delta-k+offset] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+
+	// NOTE: unreachable, since the searches always meet by the middle of the path
+	return 0, 0, n, m
+}
+
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), 8000)], 0) != -1
+}
+
+/*
+ * `configpp diff [config...]` prints how the installed configs differ from the repo.
+ */
+func runDiff(configs []Config) error {
+	differences := 0
+
+	for _, config := range configs {
+		differs, err := diffConfig(os.Stdout, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error diffing [%s]: %v\n", config.name, err)
+			continue
+		}
+
+		if differs {
+			differences++
+		}
+	}
+
+	if differences == 0 {
+		fmt.Printf("All %d configs match their installed copies\n", len(configs))
+	}
+
+	return nil
+}
+
+func sortedKeys(files map[string]string) []string {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+/*
+ * Splits content into lines that keep their "\n", so a missing newline at the end of
+ * a file shows up as a difference.
+ */
+func splitLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+/*
+ * Writes the unified diff of two files, with the repo copy as the "old" side. A
+ * template is diffed as what it renders.
+ */
+func writeFileDiff(out io.Writer, config Config, repoPath string, installPath string) error {
+	repoContent, err := readRepoFile(config, repoPath)
+	if err != nil {
+		return err
+	}
+	installContent, err := os.ReadFile(installPath)
+	if err != nil {
+		return err
+	}
+
+	// Secrets are never printed
+	if isSecret(repoPath) {
+		fmt.Fprintf(out, "Secret files %s and %s differ\n", repoPath, installPath)
+		return nil
+	}
+
+	if isBinary(repoContent) || isBinary(installContent) {
+		fmt.Fprintf(out, "Binary files %s and %s differ\n", repoPath, installPath)
+		return nil
+	}
+
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", repoPath, installPath)
+	writeHunks(out, diffLines(splitLines(repoContent), splitLines(installContent)))
+
+	return nil
+}
+
+/*
+ * Writes the edit script as unified diff hunks with `DiffContext` lines of context.
+ */
+func writeHunks(out io.Writer, ops []diffOp) {
+	// The number of old and new lines preceding each op
+	aBefore := make([]int, len(ops)+1)
+	bBefore := make([]int, len(ops)+1)
+	for i, op := range ops {
+		aBefore[i+1] = aBefore[i] + boolToInt(op.kind != '+')
+		bBefore[i+1] = bBefore[i] + boolToInt(op.kind != '-')
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Changes separated by fewer than two contexts worth of lines share a hunk
+		end := i
+		for j := i; j < len(ops) && j-end <= 2*DiffContext; j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			}
+		}
+
+		start := max(i-DiffContext, 0)
+		stop := min(end+DiffContext+1, len(ops))
+
+		aCount := aBefore[stop] - aBefore[start]
+		bCount := bBefore[stop] - bBefore[start]
+		fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aBefore[start]+boolToInt(aCount > 0), aCount, bBefore[start]+boolToInt(bCount > 0), bCount)
+
+		for _, op := range ops[start:stop] {
+			fmt.Fprintf(out, "%c%s", op.kind, op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				fmt.Fprintf(out, "\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = stop
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriteHunks(t *testing.T) {
+	repo := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	installed := "a\nb\nc\nD\ne\nf\ng\nh\ni\nj\nk\nl"
+	expect := `@@ -1,7 +1,7 @@
+ a
+ b
+ c
+-d
++D
+ e
+ f
+ g
+@@ -9,3 +9,4 @@
+ i
+ j
+ k
++l
+\ No newline at end of file
+`
+
+	var out bytes.Buffer
+	writeHunks(&out, diffLines(splitLines([]byte(repo)), splitLines([]byte(installed))))
+
+	if out.String() != expect {
+		t.Errorf("Unified diff not as expected:\n%s\nexpect:\n%s", out.String(), expect)
+	}
+
+	// Identical content has no hunks
+	out.Reset()
+	writeHunks(&out, diffLines(splitLines([]byte(repo)), splitLines([]byte(repo))))
+
+	if out.Len() != 0 {
+		t.Errorf("Expected no hunks for identical content; got:\n%s", out.String())
+	}
+
+	// Rewritten files are still minimal: every line removed and added, once
+	var before, after []string
+	for i := range 5000 {
+		before = append(before, fmt.Sprintf("before %d", i))
+		after = append(after, fmt.Sprintf("after %d", i))
+	}
+	ops := diffLines(before, after)
+	if len(ops) != 10000 || ops[0].kind != '-' || ops[len(ops)-1].kind != '+' {
+		t.Errorf("Expected 5000 removed and 5000 added lines; ops: %d", len(ops))
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	repoDir, err := os.MkdirTemp("", "diff_repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repoDir)
+
+	installDir, err := os.MkdirTemp("", "diff_install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(installDir)
+
+	executeCommand(repoDir, "bash", "-c", "mkdir .git lua && echo repo > init.lua && touch lua/repo-only.lua .git/HEAD")
+	executeCommand(installDir, "bash", "-c", "echo installed > init.lua && touch installed-only.lua")
+
+	config := Config{
+		name:                  "nvim",
+		dir:                   true,
+		localInstallPath:      InstallPaths{DefaultInstallKey: installDir},
+		localDotfilesRepoPath: repoDir,
+	}
+
+	var out bytes.Buffer
+	differs, err := diffConfig(&out, config)
+	if err != nil || !differs {
+		t.Fatalf("Expected differences without an error; differs: %v; err: %v", differs, err)
+	}
+
+	expectations := []string{
+		"-repo\n+installed\n",
+		"Only in repo: " + repoDir + "/lua/repo-only.lua",
+		"Only installed: " + installDir + "/installed-only.lua",
+	}
+	for _, expect := range expectations {
+		if !strings.Contains(out.String(), expect) {
+			t.Errorf("Expected diff output to contain %q; output:\n%s", expect, out.String())
+		}
+	}
+
+	if strings.Contains(out.String(), ".git") {
+		t.Errorf("Expected .git to be excluded from the diff; output:\n%s", out.String())
+	}
+
+	// Identical sides do not differ
+	executeCommand(repoDir, "bash", "-c", "rm -rf lua && echo installed > init.lua && touch installed-only.lua")
+
+	out.Reset()
+	differs, err = diffConfig(&out, config)
+	if err != nil || differs {
+		t.Errorf("Expected no differences; differs: %v; err: %v; output:\n%s", differs, err, out.String())
+	}
+}
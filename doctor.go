@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+)
+
+/*
+ * DoctorCheck
+ *
+ * The outcome of one `configpp doctor` check. A check with an `err` fails, unless it is
+ * a `warning`, which is reported without failing the run.
+ */
+type DoctorCheck struct {
+	name    string
+	err     error
+	warning bool
+}
+
+/*
+ * Returns the checks of the git repo in ConfigsSrc.
+ */
+func checkRepo() []DoctorCheck {
+	if _, err := exec.LookPath("git"); err != nil {
+		return []DoctorCheck{{name: "git is installed", err: err}}
+	}
+
+	checks := []DoctorCheck{{name: "git is installed"}}
+
+	git, err := getConfigsGit()
+	if err != nil {
+		// NOTE: the manifest checks report why it could not be read
+		git = newGit(ConfigsSrc)
+	}
+
+	_, err = git.headCommit()
+	checks = append(checks, DoctorCheck{name: fmt.Sprintf("[%s] is a git repo with commits", ConfigsSrc), err: err})
+	if err != nil {
+		return checks
+	}
+
+	_, err = git.remoteURL(git.remote)
+	checks = append(checks, DoctorCheck{name: fmt.Sprintf("the %q remote is set", git.remote), err: err})
+
+	err = git.checkBranch()
+	checks = append(checks, DoctorCheck{name: fmt.Sprintf("%s is checked out", git.branch), err: err})
+
+	return checks
+}
+
+/*
+ * Returns the checks of the manifest and each config's repo and install paths.
+ */
+func checkManifest() []DoctorCheck {
+	if _, err := os.Stat(replaceTildeInPath(*FlagManifest)); errors.Is(err, fs.ErrNotExist) {
+		return []DoctorCheck{{name: fmt.Sprintf("[%s] exists", *FlagManifest), err: errors.New("using the built-in configs"), warning: true}}
+	}
+
+	configs, err := loadManifest(*FlagManifest)
+	checks := []DoctorCheck{{name: fmt.Sprintf("[%s] is valid", *FlagManifest), err: err}}
+	if err != nil {
+		return checks
+	}
+
+	configs, err = selectProfileConfigs(configs, nil)
+	checks = append(checks, DoctorCheck{name: "this machine's profile is known", err: err})
+	if err != nil {
+		return checks
+	}
+
+	for _, config := range configs {
+		_, err := os.Stat(config.localDotfilesRepoPath)
+		checks = append(checks, DoctorCheck{name: fmt.Sprintf("%s: [%s] exists", config.name, config.localDotfilesRepoPath), err: err})
+
+		installPath, err := getOSSpecificDestionationPath(config)
+		if err != nil {
+			checks = append(checks, DoctorCheck{name: fmt.Sprintf("%s: installed on this machine", config.name), err: err, warning: true})
+			continue
+		}
+
+		if config.mode == ModeSymlink {
+			// NOTE: the next pull links it, unless it links somewhere else
+			err = checkLink(config, installPath)
+			checks = append(checks, DoctorCheck{name: fmt.Sprintf("%s: [%s] is linked", config.name, installPath), err: err, warning: true})
+			continue
+		}
+
+		// NOTE: a missing install path is created by the next pull
+		_, err = os.Stat(installPath)
+		checks = append(checks, DoctorCheck{name: fmt.Sprintf("%s: [%s] exists", config.name, installPath), err: err, warning: true})
+	}
+
+	return checks
+}
+
+/*
+ * Returns the checks of the state directory and the merge tool.
+ */
+func checkState() []DoctorCheck {
+	_, err := loadState()
+	checks := []DoctorCheck{{name: fmt.Sprintf("[%s] is readable", getStatePath()), err: err}}
+
+	err = os.MkdirAll(getStateDir(), 0755)
+	if err == nil {
+		var file *os.File
+		if file, err = os.CreateTemp(getStateDir(), "doctor"); err == nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}
+	checks = append(checks, DoctorCheck{name: fmt.Sprintf("[%s] is writable", getStateDir()), err: err})
+
+	tool := getMergeTool()
+	_, err = exec.LookPath(tool[0])
+	checks = append(checks, DoctorCheck{name: fmt.Sprintf("the merge tool (%s) is installed", tool[0]), err: err, warning: true})
+
+	return checks
+}
+
+/*
+ * `configpp doctor` checks everything configpp depends on and prints what is wrong.
+ * Returns an error when any check fails.
+ */
+func runDoctor(out io.Writer) error {
+	var checks []DoctorCheck
+	checks = append(checks, checkRepo()...)
+	checks = append(checks, checkManifest()...)
+	checks = append(checks, checkState()...)
+
+	failures := 0
+	for _, check := range checks {
+		switch {
+		case check.err == nil:
+			fmt.Fprintf(out, "ok    %s\n", check.name)
+		case check.warning:
+			fmt.Fprintf(out, "warn  %s: %v\n", check.name, check.err)
+		default:
+			fmt.Fprintf(out, "FAIL  %s: %v\n", check.name, check.err)
+			failures++
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d check(s) failed", failures)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestRunDoctor(t *testing.T) {
+	gitCreateSandbox(func(dir string) {
+		configsSandbox(t, dir)
+		os.WriteFile(path.Join(dir, ManifestFile), []byte(`{"configs": [{"name": "nvim", "dir": true, "repo": "nvim", "install": "/tmp/configpp-doctor-nvim"}]}`), 0644)
+
+		// Sad path - the repo copy of nvim is missing
+		var out bytes.Buffer
+		if err := runDoctor(&out); err == nil {
+			t.Errorf("Expected an error when a repo path is missing:\n%s", out.String())
+		}
+		if !strings.Contains(out.String(), "FAIL  nvim: ["+path.Join(dir, "nvim")+"] exists") {
+			t.Errorf("Expected the missing repo path to be reported:\n%s", out.String())
+		}
+
+		// Happy path - a missing install path is only a warning
+		os.Mkdir(path.Join(dir, "nvim"), 0755)
+
+		out.Reset()
+		if err := runDoctor(&out); err != nil {
+			t.Errorf("Unexpected error: %v\n%s", err, out.String())
+		}
+		if !strings.Contains(out.String(), "warn  nvim: [/tmp/configpp-doctor-nvim] exists") {
+			t.Errorf("Expected the missing install path to be a warning:\n%s", out.String())
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Extensions of the files a fonts config installs; everything else in its repo path is ignored
+var FontExtensions = []string{".otf", ".ttc", ".ttf"}
+
+// Where a fonts config installs when the manifest does not say
+var FontInstallPaths = InstallPaths{
+	"darwin": "~/Library/Fonts",
+	"linux":  "~/.local/share/fonts",
+}
+
+func isFont(filePath string) bool {
+	return slices.Contains(FontExtensions, strings.ToLower(path.Ext(filePath)))
+}
+
+/*
+ * Narrows the repo and installed files of a fonts config, keyed like `collectFiles`, to
+ * the repo's fonts, since the font directory is shared with fonts configpp does not own.
+ */
+func ownFonts(repoFiles map[string]string, installFiles map[string]string) (map[string]string, map[string]string) {
+	fonts, installed := map[string]string{}, map[string]string{}
+
+	for rel, value := range repoFiles {
+		if !isFont(rel) {
+			continue
+		}
+		fonts[rel] = value
+		if installValue, ok := installFiles[rel]; ok {
+			installed[rel] = installValue
+		}
+	}
+
+	return fonts, installed
+}
+
+/*
+ * Refreshes fontconfig's cache of the font directory so new fonts show up without
+ * logging out. Failing to is only reported, since the fonts were already installed.
+ */
+func refreshFontCache(fontsPath string, dryRun bool) {
+	if dryRun {
+		reportDryRun(getHomePath(), "fc-cache", "-f", fontsPath)
+		return
+	}
+
+	if _, err := exec.LookPath("fc-cache"); err != nil {
+		fmt.Fprintf(os.Stderr, "fc-cache is not installed; new fonts may not show up until you log in again\n")
+		return
+	}
+
+	if output, err := exec.Command("fc-cache", "-f", fontsPath).CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error refreshing the font cache: %v\n%s", err, output)
+	}
+}
+
+/*
+ * Installs the fonts of a fonts config into the OS font directory (see `FontInstallPaths`).
+ *
+ * Unlike `cpConfig`, the directory is not mirrored: fonts already installed with the same
+ * content are skipped, and only the fonts the last sync installed (see `ConfigState`) are
+ * removed once the repo drops them, and only while they still have the content it
+ * recorded. During a dry run, the changes are only summarized.
+ */
+func syncFonts(state SyncState, config Config, dryRun bool) (ChangeSummary, error) {
+	installPath, err := getOSSpecificDestionationPath(config)
+	if err != nil {
+		return ChangeSummary{}, err
+	}
+
+	matcher, err := getIgnoreMatcher(config)
+	if err != nil {
+		return ChangeSummary{}, err
+	}
+
+	repoFiles, err := collectFiles(config.localDotfilesRepoPath, matcher)
+	if err != nil {
+		return ChangeSummary{}, err
+	}
+	fonts, _ := ownFonts(repoFiles, nil)
+
+	summary := ChangeSummary{config: config.name, deletes: true, Root: installPath}
+	for _, rel := range sortedKeys(fonts) {
+		destPath := path.Join(installPath, rel)
+
+		if pathExists(destPath) {
+			repoHash, err := hashFile(fonts[rel])
+			if err != nil {
+				return summary, err
+			}
+			installHash, err := hashFile(destPath)
+			if err != nil {
+				return summary, err
+			}
+			if bytes.Equal(repoHash, installHash) {
+				summary.Unchanged++
+				continue
+			}
+			summary.Modified = append(summary.Modified, rel)
+		} else {
+			summary.Added = append(summary.Added, rel)
+		}
+
+		if dryRun {
+			continue
+		}
+		if err := copyFile(fonts[rel], destPath); err != nil {
+			return summary, err
+		}
+	}
+
+	recorded := state.config(config.name).Files
+	for _, rel := range sortedKeys(recorded) {
+		destPath := path.Join(installPath, rel)
+		if _, ok := fonts[rel]; ok || !pathExists(destPath) {
+			continue
+		}
+
+		// NOTE: a font replaced since the last sync is no longer the one configpp installed
+		installHash, err := hashFile(destPath)
+		if err != nil {
+			return summary, err
+		}
+		if hex.EncodeToString(installHash) != recorded[rel] {
+			fmt.Printf("\nKeeping [%s]; it changed since configpp installed it\n", destPath)
+			continue
+		}
+
+		summary.Deleted = append(summary.Deleted, rel)
+		if dryRun {
+			continue
+		}
+		if err := os.Remove(destPath); err != nil {
+			return summary, err
+		}
+	}
+
+	return summary, nil
+}
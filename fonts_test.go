@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path"
+	"slices"
+	"testing"
+)
+
+func TestSyncFonts(t *testing.T) {
+	repoDir := t.TempDir()
+	fontsDir := t.TempDir()
+	os.WriteFile(path.Join(repoDir, "Hack.ttf"), []byte("hack"), 0644)
+	os.WriteFile(path.Join(repoDir, "Fira.otf"), []byte("fira"), 0644)
+	os.WriteFile(path.Join(repoDir, "README.md"), []byte("patched with FontPatcher"), 0644)
+	os.WriteFile(path.Join(fontsDir, "Other.ttf"), []byte("not ours"), 0644)
+
+	config := Config{
+		name:                  "fonts",
+		dir:                   true,
+		localInstallPath:      InstallPaths{DefaultInstallKey: fontsDir},
+		localDotfilesRepoPath: repoDir,
+		kind:                  TypeFonts,
+	}
+
+	// Happy path - only fonts are installed, and other fonts are left alone
+	state := SyncState{Machines: map[string]MachineState{}}
+	summary, err := syncFonts(state, config, false)
+	if err != nil || !slices.Equal(summary.Added, []string{"Fira.otf", "Hack.ttf"}) {
+		t.Fatalf("Expected the fonts to be added; summary: %+v; err: %v", summary, err)
+	}
+	if pathExists(path.Join(fontsDir, "README.md")) || !pathExists(path.Join(fontsDir, "Other.ttf")) {
+		t.Errorf("Expected only the repo's fonts to be installed")
+	}
+	if err := recordSync(&state, config, DirectionDownstream, "", nil); err != nil {
+		t.Fatalf("Unexpected error recording the sync: %v", err)
+	}
+
+	// Installed fonts with the same content are skipped
+	summary, err = syncFonts(state, config, false)
+	if err != nil || !summary.isEmpty() || summary.Unchanged != 2 {
+		t.Errorf("Expected nothing to change; summary: %+v; err: %v", summary, err)
+	}
+
+	// Fonts dropped from the repo are removed, but never fonts configpp did not install
+	os.Remove(path.Join(repoDir, "Fira.otf"))
+	summary, err = syncFonts(state, config, false)
+	if err != nil || !slices.Equal(summary.Deleted, []string{"Fira.otf"}) {
+		t.Errorf("Expected Fira.otf to be removed; summary: %+v; err: %v", summary, err)
+	}
+	if pathExists(path.Join(fontsDir, "Fira.otf")) || !pathExists(path.Join(fontsDir, "Other.ttf")) {
+		t.Errorf("Expected only the dropped font to be removed")
+	}
+
+	// Dropped fonts replaced since the last sync are kept
+	if err := recordSync(&state, config, DirectionDownstream, "", nil); err != nil {
+		t.Fatalf("Unexpected error recording the sync: %v", err)
+	}
+	os.Remove(path.Join(repoDir, "Hack.ttf"))
+	os.WriteFile(path.Join(fontsDir, "Hack.ttf"), []byte("hack from elsewhere"), 0644)
+	summary, err = syncFonts(state, config, false)
+	if err != nil || len(summary.Deleted) != 0 || !pathExists(path.Join(fontsDir, "Hack.ttf")) {
+		t.Errorf("Expected the replaced Hack.ttf to be kept; summary: %+v; err: %v", summary, err)
+	}
+	os.WriteFile(path.Join(repoDir, "Hack.ttf"), []byte("hack"), 0644)
+	os.WriteFile(path.Join(fontsDir, "Hack.ttf"), []byte("hack"), 0644)
+
+	// Fonts never report the font directory's other fonts as drift
+	repoHashes, installHashes, err := hashConfig(config)
+	if err != nil || len(repoHashes) != 1 || len(installHashes) != 1 {
+		t.Errorf("Expected only Hack.ttf to be hashed; repo: %v; installed: %v; err: %v", repoHashes, installHashes, err)
+	}
+}
+
+func TestCPConfigsFontsBackup(t *testing.T) {
+	configsSrc := configsSandbox(t, "")
+	repoDir := path.Join(configsSrc, "fonts")
+	fontsDir := t.TempDir()
+	os.MkdirAll(repoDir, 0755)
+	os.WriteFile(path.Join(repoDir, "Hack.ttf"), []byte("patched"), 0644)
+	os.WriteFile(path.Join(fontsDir, "Hack.ttf"), []byte("original"), 0644)
+
+	config := Config{
+		name:                  "fonts",
+		dir:                   true,
+		localInstallPath:      InstallPaths{DefaultInstallKey: fontsDir},
+		localDotfilesRepoPath: repoDir,
+		kind:                  TypeFonts,
+	}
+
+	if err := cpConfigs([]Config{config}, false); err != nil {
+		t.Fatalf("Unexpected error installing fonts: %v", err)
+	}
+	if content, _ := os.ReadFile(path.Join(fontsDir, "Hack.ttf")); string(content) != "patched" {
+		t.Fatalf("Expected the repo's font to be installed; content: %q", content)
+	}
+
+	// Overwritten fonts are backed up, so the install can be undone
+	if err := runRestore([]string{"latest"}); err != nil {
+		t.Fatalf("Unexpected error restoring the latest backup: %v", err)
+	}
+	if content, _ := os.ReadFile(path.Join(fontsDir, "Hack.ttf")); string(content) != "original" {
+		t.Errorf("Expected the original font to be restored; content: %q", content)
+	}
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The remote and branch configpp syncs with unless the manifest or flags say otherwise
+const (
+	DefaultRemote = "origin"
+	DefaultBranch = "main"
+)
+
+/*
+ * Git
+ *
+ * A git client bound to one repo. Every command runs in `dir`, never the process's
+ * current working directory, so configpp behaves the same from any folder.
+ * `remote` and `branch` are what `pull` and `push` sync with.
+ */
+type Git struct {
+	dir    string
+	remote string
+	branch string
+}
+
+/*
+ * GitError
+ *
+ * A git command that failed. `args` are the arguments given to git, `exitCode` is -1
+ * when git could not be run at all, and `output` is what git printed.
+ */
+type GitError struct {
+	dir      string
+	args     []string
+	exitCode int
+	output   string
+	err      error
+}
+
+/*
+ * GitStatus
+ *
+ * The parts of `git status --porcelain=v2 --branch` configpp reports. `Branch` is
+ * "(detached)" without a branch, and `Ahead` and `Behind` count commits relative to
+ * `Upstream`, which is empty when the branch does not track one. Paths are relative to
+ * the root of the repo.
+ */
+type GitStatus struct {
+	Branch    string
+	Upstream  string
+	Ahead     int
+	Behind    int
+	Staged    []string
+	Unstaged  []string
+	Untracked []string
+	Unmerged  []string
+}
+
+/*
+ * PullConflictError
+ *
+ * A pull stopped because the rebase (`step` "rebase") or the re-apply of the local
+ * changes (`step` "stash") conflicted in `dir`. `stash` is the ref of the kept stash,
+ * `message` is its message, and `output` is git's output.
+ */
+type PullConflictError struct {
+	dir     string
+	step    string
+	stash   string
+	message string
+	output  string
+}
+
+func newGit(dir string) Git {
+	return Git{dir: dir, remote: DefaultRemote, branch: DefaultBranch}
+}
+
+func (err *GitError) Error() string {
+	message := fmt.Sprintf("`git %s` in [%s] failed: %v", strings.Join(err.args, " "), err.dir, err.err)
+	if err.output != "" {
+		message += ": " + err.output
+	}
+
+	return message
+}
+
+func (err *GitError) Unwrap() error {
+	return err.err
+}
+
+func (err *PullConflictError) Error() string {
+	if err.step == "rebase" {
+		return "your local commits conflict with the pulled commits; the rebase was aborted and nothing was copied. Run `git pull --rebase` in " + err.dir + " and resolve the conflicts"
+	}
+
+	return fmt.Sprintf("your uncommitted changes conflict with the pulled commits; they are kept in %s (%q) and nothing was copied. Run `git stash pop %s` in %s and resolve the conflicts", err.stash, err.message, err.stash, err.dir)
+}
+
+/*
+ * Stages the provided paths, relative to the repo, including new and deleted files.
+ */
+func (git Git) add(paths ...string) ([]byte, error) {
+	return git.run(append([]string{"add", "--all", "--"}, paths...)...)
+}
+
+/*
+ * Returns an error unless the configured branch is checked out, so configpp never
+ * pulls into or pushes from a branch the user did not choose.
+ */
+func (git Git) checkBranch() error {
+	status, err := git.status()
+	if err != nil {
+		return err
+	}
+
+	if status.Branch != git.branch {
+		return fmt.Errorf("[%s] is on %s, but configpp syncs %s/%s; check out %s or pass -branch %s", git.dir, status.Branch, git.remote, git.branch, git.branch, status.Branch)
+	}
+
+	return nil
+}
+
+/*
+ * Stages and commits the provided paths, relative to the repo, with the message.
+ * Anything else already staged is left out of the commit. Without paths, everything
+ * is staged and committed.
+ */
+func (git Git) commit(message string, paths ...string) ([]byte, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	if stdout, stderr := git.add(paths...); stderr != nil {
+		return stdout, stderr
+	}
+
+	return git.run(append([]string{"commit", "-m", message, "--"}, paths...)...)
+}
+
+/*
+ * Returns the staged diff of the provided paths, relative to the repo.
+ */
+func (git Git) diff(paths ...string) ([]byte, error) {
+	return git.output(append([]string{"diff", "--cached", "--stat", "--patch", "--"}, paths...)...)
+}
+
+/*
+ * Returns the ref (i.e. "stash@{1}") of the stash created with the provided message.
+ */
+func (git Git) findStash(message string) (string, error) {
+	stdout, stderr := git.output("stash", "list", "--format=%gd%x09%gs")
+	if stderr != nil {
+		return "", stderr
+	}
+
+	for _, line := range strings.Split(string(stdout), "\n") {
+		ref, subject, _ := strings.Cut(line, "\t")
+		if strings.HasSuffix(subject, ": "+message) {
+			return ref, nil
+		}
+	}
+
+	return "", fmt.Errorf("no stash named %q in [%s]", message, git.dir)
+}
+
+/*
+ * Returns the commit checked out.
+ */
+func (git Git) headCommit() (string, error) {
+	stdout, stderr := git.output("rev-parse", "HEAD")
+
+	return strings.TrimSpace(string(stdout)), stderr
+}
+
+/*
+ * Runs git and returns only what it printed to stdout, for output that is parsed.
+ */
+func (git Git) output(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = git.dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	return stdout.Bytes(), git.wrapError(args, err, stderr.Bytes())
+}
+
+/*
+ * 1. Stash the working tree, only if it is dirty, under a message unique to this pull
+ * 2. Pull `branch` from `remote` via rebase, aborting the rebase if it conflicts
+ * 3. Pop only the stash created in step 1, leaving every other stash untouched
+ *
+ * Returns a `PullConflictError` when the rebase or the re-apply conflicts, after putting
+ * the working tree back so nothing is left half merged.
+ */
+func (git Git) pull() ([]byte, error) {
+	if err := git.checkBranch(); err != nil {
+		return nil, err
+	}
+
+	status, err := git.status()
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Unmerged) > 0 {
+		return nil, fmt.Errorf("[%s] has unresolved conflicts in %s", git.dir, strings.Join(status.Unmerged, ", "))
+	}
+
+	stash := ""
+	if len(status.Staged)+len(status.Unstaged)+len(status.Untracked) > 0 {
+		stash = "configpp pull " + time.Now().Format(BackupIDLayout)
+		if stdout, stderr := git.stashPush(stash); stderr != nil {
+			return stdout, fmt.Errorf("stashing local changes: %w", stderr)
+		}
+	}
+
+	stdout, stderr := git.run("pull", "--rebase", git.remote, git.branch)
+	if stderr != nil && git.rebaseInProgress() {
+		if _, abortErr := git.run("rebase", "--abort"); abortErr != nil {
+			return stdout, fmt.Errorf("aborting the conflicted rebase: %w", abortErr)
+		}
+		stderr = &PullConflictError{dir: git.dir, step: "rebase", output: string(stdout)}
+	}
+
+	if stash == "" {
+		return stdout, stderr
+	}
+
+	if popErr := git.stashPop(stash); popErr != nil {
+		// The conflicted rebase takes precedence; the stash error explains where the changes are
+		return stdout, errors.Join(stderr, popErr)
+	}
+
+	return stdout, stderr
+}
+
+/*
+ * Pushes `branch` to `remote`, setting it as the branch's upstream.
+ */
+func (git Git) push() ([]byte, error) {
+	if err := git.checkBranch(); err != nil {
+		return nil, err
+	}
+
+	return git.run("push", "-u", git.remote, git.branch)
+}
+
+/*
+ * Returns whether a rebase stopped partway, i.e. on a conflict.
+ */
+func (git Git) rebaseInProgress() bool {
+	for _, name := range []string{"rebase-merge", "rebase-apply"} {
+		stdout, stderr := git.output("rev-parse", "--git-path", name)
+		if stderr != nil {
+			continue
+		}
+
+		gitPath := strings.TrimSpace(string(stdout))
+		if !path.IsAbs(gitPath) {
+			gitPath = path.Join(git.dir, gitPath)
+		}
+		if pathExists(gitPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+ * Returns the URL of the provided remote.
+ */
+func (git Git) remoteURL(remote string) (string, error) {
+	stdout, stderr := git.output("remote", "get-url", remote)
+
+	return strings.TrimSpace(string(stdout)), stderr
+}
+
+/*
+ * Unstages the provided paths, relative to the repo.
+ */
+func (git Git) reset(paths ...string) ([]byte, error) {
+	return git.run(append([]string{"reset", "--quiet", "--"}, paths...)...)
+}
+
+/*
+ * Runs git and returns everything it printed, for output shown to the user.
+ */
+func (git Git) run(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = git.dir
+
+	stdout, stderr := cmd.CombinedOutput()
+
+	return stdout, git.wrapError(args, stderr, stdout)
+}
+
+/*
+ * Pops the stash created with the provided message.
+ *
+ * When it conflicts with the pulled commits, the half applied changes are removed and
+ * the stash is kept, so the user can apply it and resolve the conflicts by hand.
+ */
+func (git Git) stashPop(message string) error {
+	ref, err := git.findStash(message)
+	if err != nil {
+		return err
+	}
+
+	stdout, stderr := git.run("stash", "pop", ref)
+	if stderr == nil {
+		return nil
+	}
+
+	// NOTE: the working tree was clean after stashing, so everything in it now came from
+	// the stash, which git keeps when the pop fails
+	if _, err := git.run("reset", "--hard", "--quiet"); err != nil {
+		return fmt.Errorf("resetting the conflicted stash pop: %w", err)
+	}
+	if _, err := git.run("clean", "-fd", "--quiet"); err != nil {
+		return fmt.Errorf("cleaning the conflicted stash pop: %w", err)
+	}
+
+	return &PullConflictError{dir: git.dir, step: "stash", stash: ref, message: message, output: string(stdout)}
+}
+
+/*
+ * Stashes the working tree, untracked files included, under the provided message.
+ */
+func (git Git) stashPush(message string) ([]byte, error) {
+	return git.run("stash", "push", "--include-untracked", "-m", message)
+}
+
+/*
+ * Returns the branch and working tree state, read from `git status --porcelain=v2`
+ * so it does not depend on git's human-readable wording.
+ */
+func (git Git) status() (GitStatus, error) {
+	stdout, stderr := git.output("status", "--porcelain=v2", "--branch", "-z")
+	if stderr != nil {
+		return GitStatus{}, stderr
+	}
+
+	return parseGitStatus(stdout)
+}
+
+/*
+ * Returns a `GitError` for a failed command, or nil when `err` is nil.
+ */
+func (git Git) wrapError(args []string, err error, output []byte) error {
+	if err == nil {
+		return nil
+	}
+
+	gitErr := &GitError{
+		dir:      git.dir,
+		args:     args,
+		exitCode: -1,
+		output:   strings.TrimSpace(string(output)),
+		err:      err,
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		gitErr.exitCode = exitErr.ExitCode()
+	}
+
+	return gitErr
+}
+
+/*
+ * Parses the output of `git status --porcelain=v2 --branch -z`.
+ */
+func parseGitStatus(output []byte) (GitStatus, error) {
+	var status GitStatus
+
+	entries := bytes.Split(output, []byte{0})
+	for i := 0; i < len(entries); i++ {
+		entry := string(entries[i])
+		if entry == "" {
+			continue
+		}
+
+		switch entry[0] {
+		case '#':
+			fields := strings.Fields(entry)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.head":
+				status.Branch = fields[2]
+			case "branch.upstream":
+				status.Upstream = fields[2]
+			case "branch.ab":
+				if len(fields) != 4 {
+					return status, fmt.Errorf("unexpected git status line %q", entry)
+				}
+				status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+				status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+			}
+		case '1', '2':
+			// Ordinary (1) and renamed or copied (2) entries: "<kind> <XY> <6 or 7 fields> <path>"
+			fieldCount := 9
+			if entry[0] == '2' {
+				fieldCount = 10
+				// The original path of a rename is the next entry
+				i++
+			}
+			fields := strings.SplitN(entry, " ", fieldCount)
+			if len(fields) != fieldCount || len(fields[1]) != 2 {
+				return status, fmt.Errorf("unexpected git status line %q", entry)
+			}
+			changedPath := fields[fieldCount-1]
+			if fields[1][0] != '.' {
+				status.Staged = append(status.Staged, changedPath)
+			}
+			if fields[1][1] != '.' {
+				status.Unstaged = append(status.Unstaged, changedPath)
+			}
+		case 'u':
+			fields := strings.SplitN(entry, " ", 11)
+			if len(fields) != 11 {
+				return status, fmt.Errorf("unexpected git status line %q", entry)
+			}
+			status.Unmerged = append(status.Unmerged, fields[10])
+		case '?':
+			status.Untracked = append(status.Untracked, strings.TrimPrefix(entry, "? "))
+		case '!':
+			// Ignored files are only listed with --ignored
+		default:
+			return status, fmt.Errorf("unexpected git status line %q", entry)
+		}
+	}
+
+	return status, nil
+}
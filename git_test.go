@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestGetGitStatus(t *testing.T) {
+	// Happy path - clean working tree tracking origin/main
+
+	fmt.Printf("\n\nTestGetGitStatus: Happy Path\n\n")
+
+	gitCreateSandbox(func(dir string) {
+		status, stderr := newGit(dir).status()
+
+		if stderr != nil {
+			t.Error("Expected no errors from gitStatus")
+		}
+
+		if status.Branch != "main" || status.Upstream != "origin/main" || status.Ahead != 0 || status.Behind != 0 {
+			t.Errorf("Expected main to be up to date with origin/main; status: %+v", status)
+		}
+
+		if len(status.Staged)+len(status.Unstaged)+len(status.Untracked) != 0 {
+			t.Errorf("Expected a clean working tree; status: %+v", status)
+		}
+	})
+
+	// Sad path
+	// 1. Unstaged changes
+	// 2. Staged changes
+	// 3. Untracked files and unpushed commits
+	// 4. Error contains "not a git repository"
+
+	// 1. Unstaged changes
+	gitCreateSandbox(func(dir string) {
+		gitDirtyRepoWithTrackedChange(dir)
+
+		status, stderr := newGit(dir).status()
+
+		if stderr != nil {
+			t.Error("Expected no errors from gitStatus")
+		}
+
+		if strings.Join(status.Unstaged, ",") != "README.md" || len(status.Staged) != 0 {
+			t.Errorf("Expected README.md to be unstaged; status: %+v", status)
+		}
+	})
+
+	// 2. Staged changes
+	gitCreateSandbox(func(dir string) {
+		gitDirtyRepoWithTrackedChange(dir)
+		gitAddAll(dir)
+
+		status, stderr := newGit(dir).status()
+
+		if stderr != nil {
+			t.Error("Expected no errors from gitStatus")
+		}
+
+		if strings.Join(status.Staged, ",") != "README.md" || len(status.Unstaged) != 0 {
+			t.Errorf("Expected README.md to be staged; status: %+v", status)
+		}
+	})
+
+	// 3. Untracked files and unpushed commits
+	gitCreateSandbox(func(dir string) {
+		gitDirtyRepoWithUntrackedChange(dir)
+		gitAddAll(dir)
+		gitCommit(dir)
+		gitDirtyRepoWithUntrackedChange(dir)
+
+		status, stderr := newGit(dir).status()
+
+		if stderr != nil {
+			t.Error("Expected no errors from gitStatus")
+		}
+
+		if status.Ahead != 1 || len(status.Untracked) != 0 {
+			t.Errorf("Expected one unpushed commit; status: %+v", status)
+		}
+
+		executeCommand(dir, "bash", "-c", "touch 'with space.txt'")
+
+		status, _ = newGit(dir).status()
+		if strings.Join(status.Untracked, ",") != "with space.txt" {
+			t.Errorf("Expected an untracked file; status: %+v", status)
+		}
+	})
+
+	// 4. Error contains "not a git repository"
+	_, stderr := newGit("/tmp").status()
+
+	if stderr == nil || !strings.Contains(stderr.Error(), "not a git repository") {
+		t.Errorf("Expected an error containing 'not a git repository'; error: %v", stderr)
+	}
+}
+
+func TestParseGitStatus(t *testing.T) {
+	output := strings.Join([]string{
+		"# branch.oid 0123456789abcdef",
+		"# branch.head main",
+		"# branch.upstream origin/main",
+		"# branch.ab +2 -1",
+		"1 .M N... 100644 100644 100644 0123 0123 nvim/init.lua",
+		"1 M. N... 100644 100644 100644 0123 0123 zellij/config with space.kdl",
+		"2 R. N... 100644 100644 100644 0123 0123 R100 ghostty/config",
+		"ghostty/old",
+		"u UU N... 100644 100644 100644 100644 0123 0123 0123 vim/.vimrc",
+		"? nvim/lua/",
+		"",
+	}, "\x00")
+
+	status, err := parseGitStatus([]byte(output))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if status.Branch != "main" || status.Upstream != "origin/main" || status.Ahead != 2 || status.Behind != 1 {
+		t.Errorf("Branch (%+v) not as expected", status)
+	}
+	if strings.Join(status.Staged, ",") != "zellij/config with space.kdl,ghostty/config" || strings.Join(status.Unstaged, ",") != "nvim/init.lua" {
+		t.Errorf("Changes (%+v) not as expected", status)
+	}
+	if strings.Join(status.Unmerged, ",") != "vim/.vimrc" || strings.Join(status.Untracked, ",") != "nvim/lua/" {
+		t.Errorf("Unmerged and untracked files (%+v) not as expected", status)
+	}
+
+	if count := countUncommitted(status, Nvim); count != 2 {
+		t.Errorf("Expected 2 uncommitted nvim paths; got %d", count)
+	}
+
+	// Sad path - output that is not porcelain v2
+	if _, err := parseGitStatus([]byte("On branch main\n")); err == nil {
+		t.Errorf("Expected an error for human-readable output")
+	}
+}
+
+func TestPullFromGit(t *testing.T) {
+	gitCreateSandbox(func(dir string) {
+		_, stderr := newGit(dir).pull()
+		if stderr != nil {
+			t.Errorf("There was an unexpected error from pull(): [%s]", stderr.Error())
+		}
+	})
+
+	// Happy path - uncommitted changes are stashed, pulled over, and popped without
+	// touching unrelated stashes
+
+	gitCreateSandbox(func(dir string) {
+		executeCommand(dir, "bash", "-c", "echo unrelated > unrelated.txt && git stash push --include-untracked -m unrelated")
+		executeCommand(dir, "bash", "-c", "touch other.txt && git add other.txt && git commit -m other && git push origin main")
+		gitPushFromOtherMachine(dir, "remote")
+		executeCommand(dir, "bash", "-c", "echo local > other.txt")
+		gitDirtyRepoWithUntrackedChange(dir)
+
+		_, stderr := newGit(dir).pull()
+		if stderr != nil {
+			t.Errorf("There was an unexpected error from pull(): [%s]", stderr.Error())
+		}
+
+		content, _ := os.ReadFile(path.Join(dir, "other.txt"))
+		readme, _ := os.ReadFile(path.Join(dir, "README.md"))
+		if string(content) != "local\n" || string(readme) != "remote\n" || !pathExists(path.Join(dir, "test.txt")) {
+			t.Errorf("Expected the pulled README.md and the local changes; other.txt: %q; README.md: %q", content, readme)
+		}
+
+		stashes, _ := newGit(dir).output("stash", "list", "--format=%gs")
+		if strings.TrimSpace(string(stashes)) != "On main: unrelated" {
+			t.Errorf("Expected only the unrelated stash to remain; stashes: %s", stashes)
+		}
+	})
+
+	// Sad path - uncommitted changes conflict with the pulled commits, so they are kept
+	// in their stash and the working tree matches the pulled commits
+
+	gitCreateSandbox(func(dir string) {
+		gitPushFromOtherMachine(dir, "remote")
+		gitDirtyRepoWithTrackedChange(dir)
+
+		_, stderr := newGit(dir).pull()
+
+		var conflict *PullConflictError
+		if !errors.As(stderr, &conflict) || conflict.step != "stash" || conflict.stash != "stash@{0}" {
+			t.Fatalf("Expected a stash conflict; error: %v", stderr)
+		}
+
+		readme, _ := os.ReadFile(path.Join(dir, "README.md"))
+		if string(readme) != "remote\n" {
+			t.Errorf("Expected README.md to match the pulled commit; README.md: %q", readme)
+		}
+
+		if _, err := newGit(dir).findStash(conflict.message); err != nil {
+			t.Errorf("Expected the local changes to be kept: %v", err)
+		}
+	})
+
+	// Sad path - local commits conflict with the pulled commits, so the rebase is aborted
+
+	gitCreateSandbox(func(dir string) {
+		gitPushFromOtherMachine(dir, "remote")
+		executeCommand(dir, "bash", "-c", "echo local > README.md")
+		gitAddAll(dir)
+		gitCommit(dir)
+
+		_, stderr := newGit(dir).pull()
+
+		var conflict *PullConflictError
+		if !errors.As(stderr, &conflict) || conflict.step != "rebase" {
+			t.Fatalf("Expected a rebase conflict; error: %v", stderr)
+		}
+
+		if newGit(dir).rebaseInProgress() {
+			t.Errorf("Expected the rebase to be aborted")
+		}
+
+		readme, _ := os.ReadFile(path.Join(dir, "README.md"))
+		if string(readme) != "local\n" {
+			t.Errorf("Expected the local commit to be kept; README.md: %q", readme)
+		}
+	})
+}
+
+func TestPushToGit(t *testing.T) {
+	gitCreateSandbox(func(dir string) {
+		gitDirtyRepoWithUntrackedChange(dir)
+		gitAddAll(dir)
+		gitCommit(dir)
+
+		_, stderr := newGit(dir).push()
+		if stderr != nil {
+			t.Errorf("Git Push failed; %v", stderr)
+		}
+	})
+}
+
+func TestGitCommit(t *testing.T) {
+	gitCreateSandbox(func(dir string) {
+		git := newGit(dir)
+		executeCommand(dir, "bash", "-c", "echo nvim > nvim.lua && echo zellij > zellij.kdl")
+
+		// Only the provided paths are committed
+		if _, err := git.commit("Update nvim", "nvim.lua"); err != nil {
+			t.Fatalf("Unexpected error committing: %v", err)
+		}
+
+		status, _ := git.status()
+		if strings.Join(status.Untracked, ",") != "zellij.kdl" || status.Ahead != 1 {
+			t.Errorf("Expected only nvim.lua to be committed; status: %+v", status)
+		}
+
+		// Sad path - nothing to commit is a GitError with git's exit code and output
+		_, err := git.commit("Update nothing", "nvim.lua")
+
+		var gitErr *GitError
+		if !errors.As(err, &gitErr) || gitErr.exitCode != 1 || !strings.Contains(gitErr.output, "nothing") {
+			t.Errorf("Expected a GitError for an empty commit; error: %v", err)
+		}
+	})
+}
+
+func TestGitCheckBranch(t *testing.T) {
+	gitCreateSandbox(func(dir string) {
+		git := newGit(dir)
+		if err := git.checkBranch(); err != nil {
+			t.Errorf("Unexpected error on the default branch: %v", err)
+		}
+
+		// Sad path - pull and push refuse to run on another branch
+		git.branch = "dotfiles"
+		if _, err := git.pull(); err == nil || !strings.Contains(err.Error(), "-branch main") {
+			t.Errorf("Expected pulling on the wrong branch to fail; error: %v", err)
+		}
+		if _, err := git.push(); err == nil {
+			t.Errorf("Expected pushing on the wrong branch to fail")
+		}
+
+		executeCommand(dir, "git", "checkout", "-q", "-b", "dotfiles")
+		if err := git.checkBranch(); err != nil {
+			t.Errorf("Unexpected error on the configured branch: %v", err)
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// How long a hook may run when it does not set a timeout
+const DefaultHookTimeout = 5 * time.Minute
+
+// Values of a hook's `on`, named after the commands that run it
+const (
+	HookOnPull = "pull"
+	HookOnPush = "push"
+	HookOnBoth = "both"
+)
+
+/*
+ * Hook
+ *
+ * A command a config runs before (`pre`) or after (`post`) a sync that changes it, such
+ * as reloading a program with its new config:
+ *
+ *	{ "command": ["tmux", "source-file", "~/.tmux.conf"], "timeout": "30s" }
+ *
+ * `command` is run without a shell, and arguments starting with "~" are expanded.
+ * `dir` is the working directory, defaulting to $HOME, and `env` is added to configpp's
+ * environment along with CONFIGPP_CONFIG, CONFIGPP_DIRECTION, CONFIGPP_REPO_PATH, and
+ * CONFIGPP_INSTALL_PATH. `timeout` is a Go duration, defaulting to `DefaultHookTimeout`.
+ * `on` is "pull" (the default), "push", or "both".
+ */
+type Hook struct {
+	Command []string          `json:"command"`
+	Dir     string            `json:"dir,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Timeout string            `json:"timeout,omitempty"`
+	On      string            `json:"on,omitempty"`
+}
+
+type ConfigHooks struct {
+	Pre  []Hook `json:"pre,omitempty"`
+	Post []Hook `json:"post,omitempty"`
+}
+
+/*
+ * HookFailure
+ *
+ * A hook of `config` that failed, where `stage` is "pre" or "post".
+ */
+type HookFailure struct {
+	config string
+	stage  string
+	err    error
+}
+
+/*
+ * Returns whether the hook runs when syncing in the provided direction.
+ */
+func (hook Hook) runsOn(upstream bool) bool {
+	switch hook.On {
+	case HookOnBoth:
+		return true
+	case HookOnPush:
+		return upstream
+	default:
+		return !upstream
+	}
+}
+
+/*
+ * Returns the hook's timeout; the manifest is validated on load, so an invalid timeout
+ * cannot reach here.
+ */
+func (hook Hook) timeout() time.Duration {
+	timeout, err := time.ParseDuration(hook.Timeout)
+	if err != nil || timeout <= 0 {
+		return DefaultHookTimeout
+	}
+
+	return timeout
+}
+
+/*
+ * Expands "~" at the start of an argument, since hooks are not run by a shell.
+ */
+func expandHookArg(arg string) string {
+	if arg == "~" || strings.HasPrefix(arg, "~/") {
+		return replaceTildeInPath(arg)
+	}
+
+	return arg
+}
+
+func printHookFailures(failures []HookFailure) {
+	fmt.Fprintf(os.Stderr, "-----------------------------------\n")
+	fmt.Fprintf(os.Stderr, "\n%d hook(s) failed\n", len(failures))
+
+	for _, failure := range failures {
+		fmt.Fprintf(os.Stderr, "  %s (%s): %v\n", failure.config, failure.stage, failure.err)
+	}
+}
+
+/*
+ * Runs a single hook of the config, killing it when it outlives its timeout.
+ */
+func runHook(config Config, hook Hook, upstream bool) error {
+	args := make([]string, len(hook.Command))
+	for i, arg := range hook.Command {
+		args[i] = expandHookArg(arg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hook.timeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	cmd.Dir = getHomePath()
+	if hook.Dir != "" {
+		cmd.Dir = replaceTildeInPath(hook.Dir)
+	}
+
+	direction := DirectionDownstream
+	if upstream {
+		direction = DirectionUpstream
+	}
+	// NOTE: a config without an install path on this machine is never synced, so this cannot fail
+	installPath, _ := getOSSpecificDestionationPath(config)
+
+	cmd.Env = append(os.Environ(),
+		"CONFIGPP_CONFIG="+config.name,
+		"CONFIGPP_DIRECTION="+direction,
+		"CONFIGPP_REPO_PATH="+config.localDotfilesRepoPath,
+		"CONFIGPP_INSTALL_PATH="+installPath,
+	)
+	for key, value := range hook.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("`%s` timed out after %s", strings.Join(hook.Command, " "), hook.timeout())
+	}
+	if err != nil {
+		return fmt.Errorf("`%s` failed: %w", strings.Join(hook.Command, " "), err)
+	}
+
+	return nil
+}
+
+/*
+ * Runs the hooks of a stage that apply to the direction, in order, stopping at the
+ * first failure. During a dry run, the hooks are reported instead.
+ */
+func runHooks(config Config, stage string, hooks []Hook, upstream bool, dryRun bool) error {
+	for _, hook := range hooks {
+		if !hook.runsOn(upstream) {
+			continue
+		}
+
+		if dryRun {
+			dir := getHomePath()
+			if hook.Dir != "" {
+				dir = replaceTildeInPath(hook.Dir)
+			}
+			reportDryRun(dir, hook.Command[0], hook.Command[1:]...)
+			continue
+		}
+
+		fmt.Printf("\nRunning %s-sync hook of [%s]: %s\n", stage, config.name, strings.Join(hook.Command, " "))
+		if err := runHook(config, hook, upstream); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Returns every problem with a config's hooks, prefixing each with `label`.
+ */
+func validateHooks(label string, hooks ConfigHooks) []error {
+	var errs []error
+
+	for _, hook := range append(slices.Clone(hooks.Pre), hooks.Post...) {
+		hookLabel := fmt.Sprintf("%s: hook `%s`", label, strings.Join(hook.Command, " "))
+
+		if len(hook.Command) == 0 || hook.Command[0] == "" {
+			errs = append(errs, fmt.Errorf("%s: missing \"command\"", hookLabel))
+		}
+		if hook.Dir != "" && !path.IsAbs(replaceTildeInPath(hook.Dir)) {
+			errs = append(errs, fmt.Errorf("%s: \"dir\" %q must be absolute or start with \"~\"", hookLabel, hook.Dir))
+		}
+		if hook.Timeout != "" {
+			if timeout, err := time.ParseDuration(hook.Timeout); err != nil || timeout <= 0 {
+				errs = append(errs, fmt.Errorf("%s: \"timeout\" %q must be a positive duration, such as \"30s\"", hookLabel, hook.Timeout))
+			}
+		}
+		if hook.On != "" && hook.On != HookOnPull && hook.On != HookOnPush && hook.On != HookOnBoth {
+			errs = append(errs, fmt.Errorf("%s: \"on\" must be %q, %q, or %q", hookLabel, HookOnPull, HookOnPush, HookOnBoth))
+		}
+	}
+
+	return errs
+}
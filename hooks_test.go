@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCPConfigsHooks(t *testing.T) {
+	configsSrc := configsSandbox(t, "")
+
+	installDir := t.TempDir()
+	log := path.Join(t.TempDir(), "hooks.log")
+	os.MkdirAll(path.Join(configsSrc, "nvim"), 0755)
+	os.WriteFile(path.Join(configsSrc, "nvim", "init.lua"), []byte("repo\n"), 0644)
+
+	logHook := func(stage string) Hook {
+		return Hook{Command: []string{"sh", "-c", "echo " + stage + " $CONFIGPP_CONFIG $CONFIGPP_DIRECTION >> " + log}}
+	}
+	config := Config{
+		name:                  "nvim",
+		dir:                   true,
+		localInstallPath:      InstallPaths{DefaultInstallKey: installDir},
+		localDotfilesRepoPath: path.Join(configsSrc, "nvim"),
+		hooks:                 ConfigHooks{Pre: []Hook{logHook("pre")}, Post: []Hook{logHook("post")}},
+	}
+
+	// Happy path - hooks run around a sync that changes the config
+	if err := cpConfigs([]Config{config}, false); err != nil {
+		t.Fatalf("Unexpected error syncing: %v", err)
+	}
+	content, _ := os.ReadFile(log)
+	if string(content) != "pre nvim downstream\npost nvim downstream\n" {
+		t.Errorf("Hooks log (%q) not as expected", content)
+	}
+
+	// Hooks do not run when nothing changed, nor upstream by default
+	os.Remove(log)
+	cpConfigs([]Config{config}, false)
+	os.WriteFile(path.Join(installDir, "init.lua"), []byte("local\n"), 0644)
+	cpConfigs([]Config{config}, true)
+	if pathExists(log) {
+		t.Errorf("Expected no hooks to run")
+	}
+
+	// Sad path - a failed pre-sync hook skips the config and fails the run
+	config.hooks.Pre = []Hook{{Command: []string{"false"}}}
+	os.WriteFile(path.Join(configsSrc, "nvim", "init.lua"), []byte("changed\n"), 0644)
+	if err := cpConfigs([]Config{config}, false); err == nil || !strings.Contains(err.Error(), "1 hook(s) failed") {
+		t.Errorf("Expected the failed hook to be reported; err: %v", err)
+	}
+	content, _ = os.ReadFile(path.Join(installDir, "init.lua"))
+	if string(content) != "local\n" || pathExists(log) {
+		t.Errorf("Expected the config to be skipped; content: %q", content)
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	config := Config{name: "nvim", localInstallPath: InstallPaths{DefaultInstallKey: t.TempDir()}}
+
+	// Happy path - env and dir are set
+	dir := t.TempDir()
+	hook := Hook{Command: []string{"sh", "-c", "echo $GREETING > greeting"}, Dir: dir, Env: map[string]string{"GREETING": "hi"}}
+	if err := runHook(config, hook, false); err != nil {
+		t.Fatalf("Unexpected error running hook: %v", err)
+	}
+	content, _ := os.ReadFile(path.Join(dir, "greeting"))
+	if string(content) != "hi\n" {
+		t.Errorf("Hook output (%q) not as expected", content)
+	}
+
+	// Sad path - failures and timeouts
+	if err := runHook(config, Hook{Command: []string{"false"}}, false); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("Expected a failed hook to error; err: %v", err)
+	}
+	if err := runHook(config, Hook{Command: []string{"sleep", "5"}, Timeout: "100ms"}, false); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a slow hook to time out; err: %v", err)
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	valid := ConfigHooks{Post: []Hook{{Command: []string{"rm", "-rf", "~/.local/share/nvim"}, Dir: "~", Timeout: "30s", On: HookOnBoth}}}
+	if errs := validateHooks("nvim", valid); len(errs) != 0 {
+		t.Errorf("Unexpected errors validating hooks: %v", errs)
+	}
+
+	invalid := ConfigHooks{
+		Pre:  []Hook{{Dir: "relative"}},
+		Post: []Hook{{Command: []string{"true"}, Timeout: "soon", On: "always"}},
+	}
+	if errs := validateHooks("nvim", invalid); len(errs) != 4 {
+		t.Errorf("Expected 4 errors validating hooks; got %v", errs)
+	}
+}
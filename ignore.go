@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Name of the global ignore file in ConfigsSrc, which applies to every config
+const IgnoreFile = ".configppignore"
+
+/*
+ * A single gitignore-style pattern.
+ *
+ * `dirOnly` patterns ended with "/" and only match directories, `negate` patterns
+ * started with "!" and re-include what an earlier pattern excluded, and `anchored`
+ * patterns contained a "/" so they match the path relative to the config's root
+ * instead of a name at any depth.
+ */
+type ignoreRule struct {
+	regexp   *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+/*
+ * IgnoreMatcher
+ *
+ * Decides which paths, relative to a config's root, are synced. Paths matching
+ * `exclude` are skipped (the last matching pattern wins, like .gitignore), and when
+ * `include` is not empty only files matching one of its patterns are synced.
+ */
+type IgnoreMatcher struct {
+	exclude []ignoreRule
+	include []ignoreRule
+}
+
+func newIgnoreMatcher(include []string, exclude []string) (IgnoreMatcher, error) {
+	var matcher IgnoreMatcher
+	var err error
+
+	if matcher.include, err = parseIgnoreRules(include); err != nil {
+		return matcher, err
+	}
+	if matcher.exclude, err = parseIgnoreRules(exclude); err != nil {
+		return matcher, err
+	}
+
+	return matcher, nil
+}
+
+/*
+ * Returns whether the path, relative to the config's root, should not be synced.
+ * A path inside an excluded directory is excluded too.
+ */
+func (matcher IgnoreMatcher) excluded(rel string, isDir bool) bool {
+	dirs := strings.Split(rel, "/")
+	for i := 1; i < len(dirs); i++ {
+		if matchRules(matcher.exclude, strings.Join(dirs[:i], "/"), true) {
+			return true
+		}
+	}
+
+	if matchRules(matcher.exclude, rel, isDir) {
+		return true
+	}
+
+	// Directories are always walked so included files deeper inside are found
+	if isDir || len(matcher.include) == 0 {
+		return false
+	}
+
+	return !matchRules(matcher.include, rel, false)
+}
+
+/*
+ * Converts a gitignore-style glob into a regular expression: "*" and "?" stay within a
+ * single path segment, "**" crosses segments, and "[...]" is a character class.
+ */
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var builder strings.Builder
+	builder.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch char := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			builder.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			builder.WriteString(".*")
+			i++
+		case char == '*':
+			builder.WriteString("[^/]*")
+		case char == '?':
+			builder.WriteString("[^/]")
+		case char == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("pattern %q has an unterminated \"[\"", glob)
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			builder.WriteString("[" + class + "]")
+			i += end
+		case char == '\\' && i+1 < len(glob):
+			builder.WriteString(regexp.QuoteMeta(string(glob[i+1])))
+			i++
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+
+	builder.WriteString("$")
+
+	return regexp.Compile(builder.String())
+}
+
+/*
+ * Returns the patterns every sync of the config applies: .git, the global ignore
+ * file in ConfigsSrc, and the config's own `exclude` patterns, in that order.
+ */
+func getExcludePatterns(config Config) ([]string, error) {
+	patterns := []string{".git"}
+
+	globalPatterns, err := readIgnoreFile(path.Join(ConfigsSrc, IgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+
+	patterns = append(patterns, globalPatterns...)
+
+	return append(patterns, config.exclude...), nil
+}
+
+/*
+ * Returns the matcher for the config's include and exclude patterns.
+ */
+func getIgnoreMatcher(config Config) (IgnoreMatcher, error) {
+	exclude, err := getExcludePatterns(config)
+	if err != nil {
+		return IgnoreMatcher{}, err
+	}
+
+	return newIgnoreMatcher(config.include, exclude)
+}
+
+func matchRules(rules []ignoreRule, rel string, isDir bool) bool {
+	matched := false
+
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := rel
+		if !rule.anchored {
+			target = path.Base(rel)
+		}
+
+		if rule.regexp.MatchString(target) {
+			matched = !rule.negate
+		}
+	}
+
+	return matched
+}
+
+/*
+ * Parses gitignore-style patterns, skipping blank lines and "#" comments.
+ */
+func parseIgnoreRules(patterns []string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if rest, ok := strings.CutPrefix(pattern, "!"); ok {
+			rule.negate = true
+			pattern = rest
+		}
+		if rest, ok := strings.CutSuffix(pattern, "/"); ok {
+			rule.dirOnly = true
+			pattern = rest
+		}
+		// A "/" anywhere but the end ties the pattern to the root
+		if strings.Contains(pattern, "/") {
+			rule.anchored = true
+			pattern = strings.TrimPrefix(pattern, "/")
+		}
+
+		compiled, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		rule.regexp = compiled
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+/*
+ * Returns the lines of an ignore file, or nothing when it does not exist.
+ */
+func readIgnoreFile(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
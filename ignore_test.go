@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreMatcherExcluded(t *testing.T) {
+	type IgnoreTest struct {
+		include []string
+		exclude []string
+		rel     string
+		isDir   bool
+		expect  bool
+	}
+
+	tests := []IgnoreTest{
+		// Unanchored names match at any depth
+		{exclude: []string{"lazy-lock.json"}, rel: "lazy-lock.json", expect: true},
+		{exclude: []string{"*.swp"}, rel: "lua/plugins/.init.lua.swp", expect: true},
+		{exclude: []string{"*.swp"}, rel: "lua/init.lua", expect: false},
+		// Anchored patterns match from the config's root
+		{exclude: []string{"/cache"}, rel: "cache", isDir: true, expect: true},
+		{exclude: []string{"/cache"}, rel: "themes/cache", isDir: true, expect: false},
+		{exclude: []string{"lua/*.bak"}, rel: "lua/init.bak", expect: true},
+		{exclude: []string{"lua/*.bak"}, rel: "lua/plugins/init.bak", expect: false},
+		// "**" crosses directories
+		{exclude: []string{"**/cache/**"}, rel: "a/b/cache/shaders.bin", expect: true},
+		{exclude: []string{"themes/**/*.tmp"}, rel: "themes/dark/x/y.tmp", expect: true},
+		// Directory-only patterns and their contents
+		{exclude: []string{"cache/"}, rel: "cache", expect: false},
+		{exclude: []string{"cache/"}, rel: "cache/shaders.bin", expect: true},
+		// Negation re-includes, and the last matching pattern wins
+		{exclude: []string{"*.json", "!package.json"}, rel: "package.json", expect: false},
+		{exclude: []string{"*.json", "!package.json"}, rel: "lazy-lock.json", expect: true},
+		// Comments and blank lines are ignored
+		{exclude: []string{"# comment", "", "*.log"}, rel: "# comment", expect: false},
+		// Includes narrow files but never directories
+		{include: []string{"*.lua"}, rel: "init.lua", expect: false},
+		{include: []string{"*.lua"}, rel: "README.md", expect: true},
+		{include: []string{"*.lua"}, rel: "lua", isDir: true, expect: false},
+		// Excludes beat includes
+		{include: []string{"*.lua"}, exclude: []string{"scratch.lua"}, rel: "scratch.lua", expect: true},
+	}
+
+	for _, test := range tests {
+		matcher, err := newIgnoreMatcher(test.include, test.exclude)
+		if err != nil {
+			t.Fatalf("Unexpected error creating a matcher: %v", err)
+		}
+
+		if matcher.excluded(test.rel, test.isDir) != test.expect {
+			t.Errorf("excluded(%q) should be %v; include: %v; exclude: %v", test.rel, test.expect, test.include, test.exclude)
+		}
+	}
+
+	// Sad path - invalid pattern
+	if _, err := newIgnoreMatcher(nil, []string{"[abc"}); err == nil {
+		t.Errorf("Expected an error for an unterminated character class")
+	}
+}
+
+func TestSyncPathWithPatterns(t *testing.T) {
+	src, err := os.MkdirTemp("", "ignore_src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+
+	dest, err := os.MkdirTemp("", "ignore_dest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dest)
+
+	executeCommand(src, "bash", "-c", "mkdir -p lua cache && touch init.lua lazy-lock.json lua/.plugins.lua.swp lua/plugins.lua cache/shaders.bin")
+
+	summary, err := syncPath(src, dest, SyncOptions{Exclude: []string{"lazy-lock.json", "*.swp", "cache/"}})
+	if err != nil {
+		t.Fatalf("Unexpected error syncing: %v", err)
+	}
+
+	if strings.Join(summary.Added, ",") != "init.lua,lua/plugins.lua" {
+		t.Errorf("Synced files (%v) not as expected", summary.Added)
+	}
+}
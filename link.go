@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Values of a config's `mode`
+const (
+	ModeCopy    = "copy"
+	ModeSymlink = "symlink"
+)
+
+/*
+ * LinkState
+ *
+ * What is at the install path of a symlinked config. `exists` is false when there is
+ * nothing, `target` is the absolute path a symlink points to (empty for a real file or
+ * directory), and `broken` is true when that target does not exist.
+ */
+type LinkState struct {
+	exists bool
+	target string
+	broken bool
+}
+
+/*
+ * Returns whether the install path is a symlink to `repoPath`.
+ */
+func (state LinkState) linksTo(repoPath string) bool {
+	return state.target != "" && path.Clean(state.target) == path.Clean(repoPath)
+}
+
+/*
+ * Returns why a symlinked config is not linked on this machine, or nil when its install
+ * path links to its repo path.
+ */
+func checkLink(config Config, installPath string) error {
+	state, err := getLinkState(installPath)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case state.linksTo(config.localDotfilesRepoPath):
+		return nil
+	case !state.exists:
+		return fmt.Errorf("[%s] is not linked yet", installPath)
+	case state.broken:
+		return fmt.Errorf("[%s] is a broken link to [%s]", installPath, state.target)
+	case state.target != "":
+		return fmt.Errorf("[%s] links to [%s] instead of [%s]", installPath, state.target, config.localDotfilesRepoPath)
+	default:
+		return fmt.Errorf("[%s] is a copy instead of a link to [%s]", installPath, config.localDotfilesRepoPath)
+	}
+}
+
+/*
+ * Returns the first template or secret in the repo path of a config, or "" when it has
+ * none.
+ */
+func findUnlinkable(config Config) (string, error) {
+	if !config.dir {
+		if templatePath, ok := findTemplate(config.localDotfilesRepoPath); ok {
+			return templatePath, nil
+		}
+		secretPath, _ := findSecret(config.localDotfilesRepoPath)
+		return secretPath, nil
+	}
+
+	matcher, err := getIgnoreMatcher(config)
+	if err != nil {
+		return "", err
+	}
+
+	files, err := collectFiles(config.localDotfilesRepoPath, matcher)
+	if err != nil {
+		return "", err
+	}
+
+	for _, rel := range sortedKeys(files) {
+		if strings.HasSuffix(rel, TemplateSuffix) || isSecret(rel) {
+			return files[rel], nil
+		}
+	}
+
+	return "", nil
+}
+
+func getLinkState(installPath string) (LinkState, error) {
+	info, err := os.Lstat(installPath)
+	if os.IsNotExist(err) {
+		return LinkState{}, nil
+	}
+	if err != nil {
+		return LinkState{}, err
+	}
+
+	state := LinkState{exists: true}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return state, nil
+	}
+
+	target, err := os.Readlink(installPath)
+	if err != nil {
+		return state, err
+	}
+	if !path.IsAbs(target) {
+		target = path.Join(path.Dir(installPath), target)
+	}
+
+	// NOTE: stat follows every link in a chain, so a link to a broken link is broken too
+	_, err = os.Stat(installPath)
+	state.target = target
+	state.broken = err != nil
+
+	return state, nil
+}
+
+/*
+ * Links the install path of a symlinked config to its repo path, so edits on either
+ * side are the same edit.
+ *
+ * A broken link is replaced, and a real file or directory is moved into the run's
+ * backup first. A link to anything else is left alone and reported, since it belongs
+ * to something other than configpp.
+ *
+ * Returns whether the install path was (or, during a dry run, would be) linked.
+ */
+func linkConfig(backup *Backup, config Config, dryRun bool) (bool, error) {
+	installPath, err := getOSSpecificDestionationPath(config)
+	if err != nil {
+		return false, err
+	}
+
+	if !pathExists(config.localDotfilesRepoPath) {
+		return false, fmt.Errorf("cannot link to [%s]: it does not exist", config.localDotfilesRepoPath)
+	}
+
+	// A link would install templates and secrets as they are in the repo
+	unlinkable, err := findUnlinkable(config)
+	if err != nil {
+		return false, err
+	}
+	if unlinkable != "" {
+		return false, fmt.Errorf("cannot link to [%s]: [%s] has to be rendered or decrypted, so the config needs \"mode\": %q", config.localDotfilesRepoPath, unlinkable, ModeCopy)
+	}
+
+	state, err := getLinkState(installPath)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case state.linksTo(config.localDotfilesRepoPath):
+		fmt.Printf("\n%s: [%s] already links to [%s]\n", config.name, installPath, config.localDotfilesRepoPath)
+		return false, nil
+	case state.target != "" && !state.broken:
+		return false, fmt.Errorf("[%s] links to [%s]; remove it to link [%s] instead", installPath, state.target, config.localDotfilesRepoPath)
+	case dryRun:
+		fmt.Printf("\n[dry-run] Would link [%s] to [%s]", installPath, config.localDotfilesRepoPath)
+		if state.exists {
+			fmt.Printf(", replacing what is there")
+		}
+		fmt.Printf("\n")
+		return true, nil
+	case state.target != "":
+		if err := os.Remove(installPath); err != nil {
+			return false, err
+		}
+	case state.exists:
+		if err := backupReplaced(backup, config.name, installPath); err != nil {
+			return false, fmt.Errorf("backing up [%s]: %w", installPath, err)
+		}
+	}
+
+	if err := os.MkdirAll(path.Dir(installPath), 0755); err != nil {
+		return false, err
+	}
+	if err := os.Symlink(config.localDotfilesRepoPath, installPath); err != nil {
+		return false, err
+	}
+
+	fmt.Printf("\n%s: linked [%s] to [%s]\n", config.name, installPath, config.localDotfilesRepoPath)
+
+	return true, nil
+}
+
+/*
+ * Moves a file or directory, copying it when it cannot be renamed, such as across
+ * file systems.
+ */
+func moveAll(src string, dest string) error {
+	if err := os.MkdirAll(path.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+
+	if _, err := syncPath(src, dest, SyncOptions{}); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(src)
+}
+
+/*
+ * Returns the path to walk for a root that may be a symlink, so a linked config is
+ * read through its link.
+ */
+func resolveRoot(root string) (string, error) {
+	info, err := os.Lstat(root)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return root, err
+	}
+
+	return filepath.EvalSymlinks(root)
+}
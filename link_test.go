@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestLinkConfig(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	repoDir := t.TempDir()
+	installDir := path.Join(t.TempDir(), "nvim")
+	executeCommand(repoDir, "bash", "-c", "echo repo > init.lua")
+	os.Mkdir(installDir, 0755)
+	os.WriteFile(path.Join(installDir, "init.lua"), []byte("local\n"), 0644)
+
+	config := Config{
+		name:                  "nvim",
+		dir:                   true,
+		localInstallPath:      InstallPaths{DefaultInstallKey: installDir},
+		localDotfilesRepoPath: repoDir,
+		mode:                  ModeSymlink,
+	}
+
+	// Dry runs change nothing
+	backup := newBackup()
+	if linked, err := linkConfig(backup, config, true); err != nil || !linked || checkLink(config, installDir) == nil {
+		t.Fatalf("Expected a dry run to report a link without linking; err: %v", err)
+	}
+
+	// Happy path - the real directory is backed up and replaced with a link
+	if _, err := linkConfig(backup, config, false); err != nil {
+		t.Fatalf("Unexpected error linking: %v", err)
+	}
+	if err := checkLink(config, installDir); err != nil {
+		t.Errorf("Expected the install path to link to the repo: %v", err)
+	}
+	if len(backup.Entries) != 1 || !backup.Entries[0].Linked {
+		t.Errorf("Expected the replaced directory to be backed up; entries: %+v", backup.Entries)
+	}
+
+	// Linked configs are in sync, so they never conflict
+	repoHashes, installHashes, err := hashConfig(config)
+	if err != nil || repoHashes["init.lua"] == "" || repoHashes["init.lua"] != installHashes["init.lua"] {
+		t.Errorf("Expected both sides to hash the same through the link; repo: %v; installed: %v; err: %v", repoHashes, installHashes, err)
+	}
+
+	// Linking again changes nothing
+	if linked, err := linkConfig(backup, config, false); err != nil || linked || len(backup.Entries) != 1 {
+		t.Errorf("Expected linking again to change nothing; entries: %+v; err: %v", backup.Entries, err)
+	}
+
+	// Rolling back puts the directory back in place of the link
+	if err := restoreBackup(*backup, nil); err != nil {
+		t.Fatalf("Unexpected error restoring: %v", err)
+	}
+	content, err := os.ReadFile(path.Join(installDir, "init.lua"))
+	if err != nil || string(content) != "local\n" || checkLink(config, installDir) == nil {
+		t.Errorf("Expected the original directory to be restored; content: %q; err: %v", content, err)
+	}
+
+	// Broken links are replaced
+	os.RemoveAll(installDir)
+	os.Symlink("/does/not/exist", installDir)
+	if err := checkLink(config, installDir); err == nil || !strings.Contains(err.Error(), "broken link") {
+		t.Errorf("Expected a broken link to be reported; err: %v", err)
+	}
+	if _, err := linkConfig(newBackup(), config, false); err != nil || checkLink(config, installDir) != nil {
+		t.Errorf("Expected a broken link to be replaced; err: %v", err)
+	}
+
+	// Sad path - templates and secrets cannot be linked, since they need rendering or decrypting
+	os.Remove(installDir)
+	executeCommand(repoDir, "bash", "-c", "echo '{{ .Hostname }}' > host.tmpl")
+	if _, err := linkConfig(newBackup(), config, false); err == nil || !strings.Contains(err.Error(), "host.tmpl") || pathExists(installDir) {
+		t.Errorf("Expected an error linking a config with a template; err: %v", err)
+	}
+	os.Remove(path.Join(repoDir, "host.tmpl"))
+
+	// Sad path - links to something else are left alone
+	os.Remove(installDir)
+	os.Symlink(t.TempDir(), installDir)
+	if _, err := linkConfig(newBackup(), config, false); err == nil {
+		t.Errorf("Expected an error replacing a foreign link")
+	}
+}
@@ -3,83 +3,137 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
-	"path"
 	"runtime"
+	"slices"
 	"strings"
 )
 
 /*
  * Config
  *
- * `localInstallPath` represents the local config directories, such as "~/.config/alacritty." Unlike `localDotfilesRepoPath`, it is a slice because there may be different paths for the same config depending on whether the OS is Mac OSX or Linux.
+ * `name` is the unique, lowercase identifier of a config, such as "nvim," and is how a config is referred to in the manifest.
+ * `localInstallPath` represents the local config directories, such as "~/.config/alacritty." Unlike `localDotfilesRepoPath`, it is keyed because there may be different paths for the same config depending on the OS, architecture, or machine (see `InstallPaths`).
  * `localDotfilesRepoPath` represents the local directory where all my dotfile directories are stored, which is typically ~/dev/configs/ + config.
+ * `include` and `exclude` are gitignore-style patterns, relative to the config's directory, that narrow which files are synced (see `IgnoreMatcher`).
+ * `variables` are the manifest's variables with the machine's profile applied (see `Profile`).
+ * `mode` is `ModeSymlink` when the install path links to `localDotfilesRepoPath` instead of holding a copy.
+ * `hooks` are commands run before and after a sync that changes the config (see `Hook`).
+ * `kind` is `TypeNvim` for configs whose plugins are restored after a pull (see `resetNvim`).
+ * `binary` is the program the config belongs to; pulls skip the config where it is not installed.
  */
 type Config struct {
+	name                  string
 	dir                   bool
-	localInstallPath      []string
+	localInstallPath      InstallPaths
 	localDotfilesRepoPath string
+	include               []string
+	exclude               []string
+	variables             map[string]string
+	mode                  string
+	hooks                 ConfigHooks
+	kind                  string
+	binary                string
 }
 
+/*
+ * InstallPaths
+ *
+ * Install paths keyed by where they apply. From most to least specific:
+ * "host:<hostname>", "<GOOS>/<GOARCH>" (i.e. "darwin/arm64"), "<GOOS>" (i.e. "linux"), and "default".
+ * A config without a matching key is not installed on that machine.
+ */
+type InstallPaths map[string]string
+
+// The fallback key of `InstallPaths`
+const DefaultInstallKey = "default"
+
 var (
 	Alacritty = Config{
+		name:                  "alacritty",
 		dir:                   true,
-		localInstallPath:      []string{getHomePath() + "/.config/alacritty"},
+		localInstallPath:      InstallPaths{DefaultInstallKey: getHomePath() + "/.config/alacritty"},
 		localDotfilesRepoPath: ConfigsSrc + "/alacritty",
+		binary:                "alacritty",
 	}
+	Arch        = runtime.GOARCH
 	Bashaliases = Config{
+		name:                  "bashaliases",
 		dir:                   false,
-		localInstallPath:      []string{getHomePath() + "/.bash_aliases"},
+		localInstallPath:      InstallPaths{DefaultInstallKey: getHomePath() + "/.bash_aliases"},
 		localDotfilesRepoPath: ConfigsSrc + "/bash/.bash_aliases",
 	}
 	Bashrc = Config{
+		name:                  "bashrc",
 		dir:                   false,
-		localInstallPath:      []string{getHomePath() + "/.bashrc"},
+		localInstallPath:      InstallPaths{DefaultInstallKey: getHomePath() + "/.bashrc"},
 		localDotfilesRepoPath: ConfigsSrc + "/bash/.bashrc",
 	}
 	ConfigsSrc = getHomePath() + "/dev/configs"
 	Eslint     = Config{
+		name:                  "eslint",
 		dir:                   true,
-		localInstallPath:      []string{ConfigsSrc + "/eslint"},
+		localInstallPath:      InstallPaths{DefaultInstallKey: ConfigsSrc + "/eslint"},
 		localDotfilesRepoPath: ConfigsSrc + "/eslint",
 	}
-	FlagUpstream = flag.Bool("u", false, "Copy local directory configurations to upstream ("+ConfigsSrc+")")
+	FlagBranch   = flag.String("branch", "", "Branch of "+ConfigsSrc+" to pull and push; overrides the manifest's \"branch\" (default \""+DefaultBranch+"\")")
+	FlagDryRun   = flag.Bool("dry-run", false, "Show what would change without copying, pulling, or pushing anything")
+	FlagForce    = flag.Bool("force", false, "Install configs even when the binary they belong to is not installed")
+	FlagManifest = flag.String("m", ConfigsSrc+"/"+ManifestFile, "Path to the manifest describing which configs to sync")
+	FlagOnly     = flag.String("only", "", "Comma separated configs to sync, such as \"nvim,ghostty\"; positional arguments are added to this list")
+	FlagProfile  = flag.String("profile", "", "Profile of this machine in the manifest, such as \"server\"; defaults to the profile whose hosts match the hostname")
+	FlagRemote   = flag.String("remote", "", "Remote of "+ConfigsSrc+" to pull from and push to; overrides the manifest's \"remote\" (default \""+DefaultRemote+"\")")
+	FlagResolve  = flag.String("resolve", "", "How to resolve files changed on both sides since the last sync: \"local\" (keep installed), \"remote\" (keep repo), or \"merge\"")
+	FlagSkip     = flag.String("skip", "", "Comma separated configs to leave untouched, such as \"eslint\"")
+	FlagUpstream = flag.Bool("u", false, "Copy local directory configurations to upstream ("+ConfigsSrc+"); same as the push command")
 	FontPatcher  = Config{
+		name:                  "fontpatcher",
 		dir:                   true,
-		localInstallPath:      []string{getHomePath() + "/dev/FontPatcher"},
+		localInstallPath:      InstallPaths{DefaultInstallKey: getHomePath() + "/dev/FontPatcher"},
 		localDotfilesRepoPath: ConfigsSrc + "/fontpatcher",
 	}
 	Ghostty = Config{
+		name:                  "ghostty",
 		dir:                   true,
-		localInstallPath:      []string{getHomePath() + "/Library/Application Support/com.mitchellh.ghostty", getHomePath() + "/.config/ghostty"},
+		localInstallPath:      InstallPaths{"darwin": getHomePath() + "/Library/Application Support/com.mitchellh.ghostty", "linux": getHomePath() + "/.config/ghostty"},
 		localDotfilesRepoPath: ConfigsSrc + "/ghostty",
+		binary:                "ghostty",
 	}
 	Nvim = Config{
+		name:                  "nvim",
 		dir:                   true,
-		localInstallPath:      []string{getHomePath() + "/.config/nvim"},
+		localInstallPath:      InstallPaths{DefaultInstallKey: getHomePath() + "/.config/nvim"},
 		localDotfilesRepoPath: ConfigsSrc + "/nvim",
+		kind:                  TypeNvim,
 	}
 	OS        = runtime.GOOS
 	Stylelint = Config{
+		name:                  "stylelint",
 		dir:                   true,
-		localInstallPath:      []string{ConfigsSrc + "/stylelint"},
+		localInstallPath:      InstallPaths{DefaultInstallKey: ConfigsSrc + "/stylelint"},
 		localDotfilesRepoPath: ConfigsSrc + "/stylelint",
 	}
-	UncommittedText = "Changes not staged for commit:"
-	Vim             = Config{
+	Vim = Config{
+		name:                  "vim",
 		dir:                   false,
-		localInstallPath:      []string{getHomePath() + "/.vimrc"},
+		localInstallPath:      InstallPaths{DefaultInstallKey: getHomePath() + "/.vimrc"},
 		localDotfilesRepoPath: ConfigsSrc + "/vim/.vimrc",
 	}
 	Zellij = Config{
+		name:                  "zellij",
 		dir:                   true,
-		localInstallPath:      []string{getHomePath() + "/.config/zellij"},
+		localInstallPath:      InstallPaths{DefaultInstallKey: getHomePath() + "/.config/zellij"},
 		localDotfilesRepoPath: ConfigsSrc + "/zellij",
+		binary:                "zellij",
 	}
 
+	// The built-in configs double as the default manifest when ConfigsSrc
+	// does not contain one
 	Configs = []Config{
 		Alacritty,
 		Bashaliases,
@@ -94,6 +148,63 @@ var (
 	}
 )
 
+/*
+ * Snapshots the local files a downstream copy of the config would overwrite into
+ * the run's backup. A fonts config is previewed with `syncFonts`, which may also
+ * remove fonts.
+ */
+func backupConfig(backup *Backup, state SyncState, config Config) error {
+	var summary ChangeSummary
+	var err error
+	if config.kind == TypeFonts {
+		summary, err = syncFonts(state, config, true)
+	} else {
+		summary, err = cpConfig(config, false, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	return backupFiles(backup, config.name, summary.Root, summary)
+}
+
+/*
+ * Detects conflicts across the configs and resolves them with `-resolve`.
+ *
+ * Returns an error when there are conflicts and no resolution, except during a dry run,
+ * which only reports them.
+ */
+func checkConflicts(state SyncState, configs []Config, upstream bool) (map[string][]string, error) {
+	var conflicts []Conflict
+	for _, config := range configs {
+		configConflicts, err := detectConflicts(state, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking [%s] for conflicts: %v\n", config.name, err)
+			continue
+		}
+		conflicts = append(conflicts, configConflicts...)
+	}
+
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+
+	if *FlagResolve == "" {
+		printConflicts(conflicts)
+		if *FlagDryRun {
+			return nil, nil
+		}
+		return nil, errors.New("unresolved conflicts")
+	}
+
+	if *FlagDryRun {
+		fmt.Printf("\n[dry-run] Would resolve %d conflict(s) with `-resolve %s`\n", len(conflicts), *FlagResolve)
+		return nil, nil
+	}
+
+	return resolveConflicts(conflicts, *FlagResolve, upstream)
+}
+
 /*
  * Sets the CWD to the provided directory.
  */
@@ -106,67 +217,275 @@ func chdir(dir string) {
 	}
 }
 
-// Copies a directory or file from one location to another via `rsync`.
+// Copies a directory or file from one location to another via `syncPath`.
 // Utilizes the current OS archiecture to discern the local directory.
-// Respects whether the user passed `-c` in the CLI call to discern
+// Respects `upstream` (the push command or `-u`) to discern
 // which direction to copy in.
-// Utilizing path.Dir to get the last directory of a path when the path
-// ends with a file, such as for Vim or Ghostty.
-// Excludes .git folders in local directories when copying.
-// Discerns whether the destination has the targeted directory when copying
-// a file instead of a directory.
-func cpConfig(config Config, upstream bool) ([]byte, error) {
-	dest, src := getRsyncPaths(config, upstream)
-	fmt.Printf("-----------------------------------\n")
-	fmt.Printf("\nCopying [%s] to [%s]\n", src, dest)
-
-	rsync := exec.Command("rsync", "-arv", "--progress", src, dest, "--exclude", ".git")
+// Excludes .git folders, the global ignore file's patterns, and the config's own
+// patterns when copying, in both directions.
+// Renders templates and decrypts secrets downstream, and re-encrypts secrets upstream
+// instead of copying their plaintext or a template's output into the repo.
+// When `dryRun` is true, nothing is copied and the summary describes what would be.
+func cpConfig(config Config, upstream bool, dryRun bool) (ChangeSummary, error) {
+	dest, src, err := getSyncPaths(config, upstream)
+	if err != nil {
+		return ChangeSummary{config: config.name}, err
+	}
+
+	exclude, err := getExcludePatterns(config)
+	if err != nil {
+		return ChangeSummary{config: config.name}, err
+	}
+
+	summary, err := syncPath(src, dest, SyncOptions{
+		DryRun:           dryRun,
+		Include:          config.include,
+		Exclude:          exclude,
+		Render:           !upstream,
+		ProtectTemplates: upstream,
+		Variables:        config.variables,
+		Decrypt:          !upstream,
+		Encrypt:          upstream,
+	})
+	summary.config = config.name
+
+	return summary, err
+}
+
+/*
+ * Copies every config in one direction (upstream when `upstream` is true), stopping
+ * before anything is copied when conflicts are not resolved. Downstream copies back up
+ * the local files they overwrite first. Symlinked configs are linked downstream instead
+ * (see `linkConfig`), and skipped upstream, as are fonts configs, which are installed
+ * downstream (see `syncFonts`).
+ *
+ * Downstream, configs whose binary is not installed are skipped unless `-force` is set,
+ * and listed at the end either way.
+ *
+ * A config's hooks only run when the sync changes it: pre-sync hooks before it is copied,
+ * skipping the config when one fails, and post-sync hooks after. Every failed hook is
+ * reported at the end, and makes the run return an error.
+ */
+func cpConfigs(configs []Config, upstream bool) error {
+	var summaries []ChangeSummary
+	backup := newBackup()
 
+	state, err := loadState()
+	if err != nil {
+		return fmt.Errorf("loading sync state: %w", err)
+	}
+
+	direction := DirectionDownstream
+	if upstream {
+		direction = DirectionUpstream
+	}
+
+	// NOTE: an upstream sync lands onto the commit the push makes next, which
+	// `recordPushCommit` records once it exists
+	var commit string
 	if !upstream {
-		return rsync.CombinedOutput()
-	} else {
-		// NOTE: cp/rsync'ing directories will create the target directory if missing
-		// but cp/rsync'ing a specific file to a non-existent directory fails
-		createMissingTargetDirectory(config, dest)
+		commit, err = newGit(ConfigsSrc).headCommit()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read the commit of [%s]; it will not be recorded: %v\n", ConfigsSrc, err)
+		}
+	}
+
+	// Configs of programs this machine does not have would only clutter it, so they are
+	// left out before their conflicts could stop the run
+	var unsupported, forced []Config
+	configs = slices.DeleteFunc(slices.Clone(configs), func(config Config) bool {
+		if upstream || !missingBinary(config) {
+			return false
+		}
+		if *FlagForce {
+			forced = append(forced, config)
+			return false
+		}
+
+		fmt.Printf("\nSkipping [%s]; %s is not installed\n", config.name, config.binary)
+		unsupported = append(unsupported, config)
+		return true
+	})
 
-		return rsync.CombinedOutput()
+	// Every conflict is found before anything is copied so a conflicted run changes nothing
+	skips, err := checkConflicts(state, configs, upstream)
+	if err != nil {
+		return err
 	}
-}
 
-func cpConfigs() {
-	for _, config := range Configs {
-		stdout, stderr := cpConfig(config, *FlagUpstream)
+	var hookFailures []HookFailure
+	for _, config := range configs {
+		skip := skips[config.name]
+		if slices.Contains(skip, ".") {
+			fmt.Printf("\nSkipping [%s]; keeping its conflicting copy\n", config.name)
+			continue
+		}
 
-		if stderr != nil {
-			fmt.Fprintf(os.Stderr, "Error while copying config - %s\n", stdout)
+		config.exclude = slices.Clone(config.exclude)
+		for _, rel := range skip {
+			// NOTE: downstream, a rendered or decrypted file's source is its template or secret
+			config.exclude = append(config.exclude, "/"+escapeGlob(rel))
+			for _, suffix := range []string{TemplateSuffix, AgeSuffix, PassphraseSuffix} {
+				config.exclude = append(config.exclude, "/"+escapeGlob(rel+suffix))
+			}
+		}
+
+		// Hooks only run for configs the sync changes, so the change is previewed first
+		if len(config.hooks.Pre) > 0 {
+			changing, err := previewChange(state, config, upstream)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Skipping [%s]; error previewing its changes: %v\n", config.name, err)
+				continue
+			}
+			if changing {
+				if err := runHooks(config, "pre", config.hooks.Pre, upstream, *FlagDryRun); err != nil {
+					fmt.Fprintf(os.Stderr, "Skipping [%s]; its pre-sync hook failed: %v\n", config.name, err)
+					hookFailures = append(hookFailures, HookFailure{config: config.name, stage: "pre", err: err})
+					continue
+				}
+			}
+		}
+
+		var changed bool
+		if config.mode == ModeSymlink {
+			// Linked configs are the same files on both sides, so there is nothing to copy
+			fmt.Printf("-----------------------------------\n")
+			if upstream {
+				fmt.Printf("\nSkipping [%s]; it is linked to [%s], so nothing needs copying\n", config.name, config.localDotfilesRepoPath)
+				continue
+			}
+
+			changed, err = linkConfig(backup, config, *FlagDryRun)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error linking [%s]: %v\n", config.name, err)
+				continue
+			}
+		} else if config.kind == TypeFonts {
+			// Fonts only flow from the repo, since the font directory holds fonts from elsewhere too
+			fmt.Printf("-----------------------------------\n")
+			if upstream {
+				fmt.Printf("\nSkipping [%s]; fonts are only installed from [%s]\n", config.name, config.localDotfilesRepoPath)
+				continue
+			}
+
+			// Never overwrite or remove fonts that could not be backed up
+			if !*FlagDryRun {
+				if err := backupConfig(backup, state, config); err != nil {
+					fmt.Fprintf(os.Stderr, "Skipping [%s]; error backing up local fonts: %v\n", config.name, err)
+					continue
+				}
+			}
+
+			fmt.Printf("\nInstalling fonts of [%s]\n", config.name)
+
+			summary, err := syncFonts(state, config, *FlagDryRun)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error installing fonts - %v\n", err)
+				continue
+			}
+
+			printChangeSummary(summary)
+			summaries = append(summaries, summary)
+			changed = !summary.isEmpty()
+
+			if changed && OS == "linux" {
+				refreshFontCache(summary.Root, *FlagDryRun)
+			}
 		} else {
-			fmt.Printf("\nRsync output: %s\n", stdout)
+			// Never overwrite local files that could not be backed up
+			if !upstream && !*FlagDryRun {
+				if err := backupConfig(backup, state, config); err != nil {
+					fmt.Fprintf(os.Stderr, "Skipping [%s]; error backing up local files: %v\n", config.name, err)
+					continue
+				}
+			}
+
+			fmt.Printf("-----------------------------------\n")
+			fmt.Printf("\nCopying [%s]\n", config.name)
+
+			summary, stderr := cpConfig(config, upstream, *FlagDryRun)
+			if stderr != nil {
+				fmt.Fprintf(os.Stderr, "Error while copying config - %v\n", stderr)
+				continue
+			}
+
+			printChangeSummary(summary)
+			summaries = append(summaries, summary)
+			changed = !summary.isEmpty()
 		}
-	}
-}
 
-func createMissingTargetDirectory(config Config, dest string) {
-	if !config.dir {
-		targetDirectory := path.Dir(dest)
-		_, statErr := os.Stat(targetDirectory)
-		if os.IsNotExist(statErr) {
-			fmt.Printf("\n [%s] does not exist in [%s]; creating missing directory", dest, ConfigsSrc)
-			fmt.Printf("\n%s", statErr)
-			if stderr := exec.Command("mkdir", path.Dir(config.localDotfilesRepoPath)).Run(); stderr != nil {
-				fmt.Printf("\nThere was an error executing `mkdir %s`\n", path.Dir(config.localDotfilesRepoPath))
+		if changed {
+			if err := runHooks(config, "post", config.hooks.Post, upstream, *FlagDryRun); err != nil {
+				hookFailures = append(hookFailures, HookFailure{config: config.name, stage: "post", err: err})
 			}
 		}
+
+		if !*FlagDryRun {
+			if err := recordSync(&state, config, direction, commit, skip); err != nil {
+				fmt.Fprintf(os.Stderr, "Error recording sync state for [%s]: %v\n", config.name, err)
+			}
+		}
+	}
+
+	if *FlagDryRun {
+		printChangeSummaries(summaries)
+	} else if err := saveState(state); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving sync state: %v\n", err)
+	}
+
+	if len(backup.Entries) > 0 {
+		fmt.Printf("\nOverwritten files were backed up to [%s]; undo with `configpp restore %s`\n", backup.dir(), backup.ID)
+	}
+
+	printMissingBinaries(unsupported, forced)
+
+	if len(hookFailures) > 0 {
+		printHookFailures(hookFailures)
+		return fmt.Errorf("%d hook(s) failed", len(hookFailures))
 	}
+
+	return nil
 }
 
-func deleteLocalShareNvim() {
-	_, stderr := exec.Command("rm", "-rf", replaceTildeInPath("~/.local/share/nvim")).CombinedOutput()
+/*
+ * Returns the Git client of ConfigsSrc, syncing with the remote and branch set by
+ * `-remote` and `-branch`, else this machine's profile, else the manifest, else "origin"
+ * and "main".
+ */
+func getConfigsGit() (Git, error) {
+	git := newGit(ConfigsSrc)
+
+	manifest, err := readManifest(*FlagManifest)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return git, fmt.Errorf("loading manifest: %w", err)
+	}
 
-	if stderr != nil {
-		fmt.Fprintf(os.Stderr, "There was an issue removing nvim's local share directory [%s]", stderr)
+	if manifest.Remote != "" {
+		git.remote = manifest.Remote
+	}
+	if manifest.Branch != "" {
+		git.branch = manifest.Branch
+	}
+
+	_, profile, err := loadProfile(*FlagManifest, *FlagProfile)
+	if err != nil {
+		return git, err
+	}
+	if profile.Remote != "" {
+		git.remote = profile.Remote
+	}
+	if profile.Branch != "" {
+		git.branch = profile.Branch
+	}
+
+	if *FlagRemote != "" {
+		git.remote = *FlagRemote
+	}
+	if *FlagBranch != "" {
+		git.branch = *FlagBranch
 	}
 
-	fmt.Println("nvim deleted from ~/.local/share/nvim")
+	return git, nil
 }
 
 func getHomePath() string {
@@ -178,27 +497,39 @@ func getHomePath() string {
 	return home
 }
 
-func getLocalDirIndex() int {
-	switch OS {
-	case "darwin":
-		return 0
-	case "linux":
-		return 1
-	default:
-		return 2
-	}
+func getHostname() string {
+	// NOTE: an unknown hostname only means "host:" keys never match
+	hostname, _ := os.Hostname()
+
+	return hostname
 }
 
-func getOSSpecificDestionationPath(config Config) string {
-	if len(config.localInstallPath) == 1 {
-		return config.localInstallPath[0]
-	} else {
-		return config.localInstallPath[getLocalDirIndex()]
+/*
+ * Returns the install path of a config for this machine, preferring the most specific
+ * key of `InstallPaths`: hostname, then OS and architecture, then OS, then "default".
+ *
+ * Returns an error instead of guessing when no key matches.
+ */
+func getOSSpecificDestionationPath(config Config) (string, error) {
+	keys := []string{
+		"host:" + getHostname(),
+		OS + "/" + Arch,
+		OS,
+		DefaultInstallKey,
+	}
+
+	for _, key := range keys {
+		if installPath, ok := config.localInstallPath[key]; ok {
+			return installPath, nil
+		}
 	}
+
+	return "", fmt.Errorf("config [%s] has no install path for %s/%s (tried %s)", config.name, OS, Arch, strings.Join(keys, ", "))
 }
 
 /*
- * Returns the dest and src paths for a `rsync` operation.
+ * Returns the dest and src paths for a `syncPath` operation. Both paths name the
+ * config itself, whether it is a directory or a single file.
  *
  * A "downstream" operation is when we pull from github, which correlates to:
  * `config.localDotfilesRepoPath` is our source path. This is our local Git repo of dotfile directories (~/dev/configs/).
@@ -206,120 +537,106 @@ func getOSSpecificDestionationPath(config Config) string {
  *
  * An "upstream" operation is when we push from our local Git repo of dotfile directories to GitHub:
  * `config.localDotfilesRepoPath` is our destination path. This is our local Git repo of dotfile directories (~/dev/configs/).
- * `config.localInstallPath` is our source path; however, if `localInstallPath` is a directory, verse a single file, we append "/" since `rsync` only copies files inside of a directory if the path ends in "/". This is our local config directories (~/.config/alacritty/).
+ * `config.localInstallPath` is our source path. This is our local config directories (~/.config/alacritty/).
  */
-func getRsyncPaths(config Config, upstream bool) (string, string) {
-	destPathByOS := getOSSpecificDestionationPath(config)
+func getSyncPaths(config Config, upstream bool) (string, string, error) {
+	destPathByOS, err := getOSSpecificDestionationPath(config)
+	if err != nil {
+		return "", "", err
+	}
 
-	var dest string
-	var src string
 	if upstream {
-		dest = config.localDotfilesRepoPath
-		if config.dir {
-			// NOTE: rsync will only copy the files inside of a directory if the source path
-			// ends in "/"
-			src = destPathByOS + "/"
-		} else {
-			src = destPathByOS
-		}
-	} else {
-		// dest should be the directory containing our target since we'll be overwriting it
-		dest = path.Dir(destPathByOS)
-		src = config.localDotfilesRepoPath
+		return config.localDotfilesRepoPath, destPathByOS, nil
 	}
 
-	return dest, src
+	return destPathByOS, config.localDotfilesRepoPath, nil
 }
 
 /*
- * Within the provided directory:
- * 1. Stash the current working tree changes
- * 2. Pull via rebase
- * 3. Apply the latest stash
+ * Loads the manifest provided by `-m` and narrows it to the configs selected with
+ * `-only`, `-skip`, and `names` (positional arguments), then to this machine's profile
+ * (see `Profile`). Every command that works on configs loads them through here.
  */
-func gitPull(dir string) ([]byte, error) {
-	cmd := exec.Command("git", "pull", "--rebase")
-	cmd.Dir = dir
-
-	gitStashBegin()
-
-	stdout, stderr := cmd.CombinedOutput()
+func loadConfigs(names []string) ([]Config, error) {
+	configs, err := loadManifest(*FlagManifest)
+	if err != nil {
+		return nil, fmt.Errorf("loading manifest: %w", err)
+	}
 
-	gitStashEnd()
+	only := append(splitConfigNames(*FlagOnly), names...)
+	configs, err = filterConfigs(configs, only, splitConfigNames(*FlagSkip))
+	if err != nil {
+		return nil, fmt.Errorf("selecting configs:\n%w", err)
+	}
 
-	return stdout, stderr
+	return selectProfileConfigs(configs, only)
 }
 
 /*
- * Pushes to "origin" remote's "main" branch and prints the output.
+ * Returns whether the config declares a binary that is not on $PATH.
  */
-func gitPush(dir string) ([]byte, error) {
-	fmt.Printf("\nUpdating Git from %s\n", dir)
-
-	cmd := exec.Command("git", "push", "-u", "origin", "main")
-	cmd.Dir = dir
-
-	stdout, stderr := cmd.CombinedOutput()
-	if stderr != nil {
-		fmt.Printf("\n%v\n", stderr)
+func missingBinary(config Config) bool {
+	if config.binary == "" {
+		return false
 	}
 
-	fmt.Printf("%s", stdout)
+	_, err := exec.LookPath(replaceTildeInPath(config.binary))
 
-	return stdout, stderr
+	return err != nil
 }
 
 /*
- * Prints the output of calling `git status`
- * Returns
+ * Returns whether syncing the config in the provided direction would change anything.
  */
-func gitStatus(dir string) ([]byte, error) {
-	cmd := exec.Command("git", "status")
-	cmd.Dir = dir
+func previewChange(state SyncState, config Config, upstream bool) (bool, error) {
+	if config.kind == TypeFonts {
+		if upstream {
+			return false, nil
+		}
+		summary, err := syncFonts(state, config, true)
+		return !summary.isEmpty(), err
+	}
 
-	stdout, stderr := cmd.CombinedOutput()
-	if stderr != nil {
-		fmt.Printf("\n%v\n", stderr)
+	if config.mode == ModeSymlink {
+		installPath, err := getOSSpecificDestionationPath(config)
+		if err != nil {
+			return false, err
+		}
+		return !upstream && checkLink(config, installPath) != nil, nil
 	}
 
-	fmt.Printf("%s", stdout)
+	summary, err := cpConfig(config, upstream, true)
 
-	return stdout, stderr
+	return !summary.isEmpty(), err
 }
 
 /*
- * Within the CWD, stashes the current working tree.
- */
-func gitStashBegin() {
-	if stderr := exec.Command("git", "stash").Run(); stderr != nil {
-		fmt.Printf("\nThere was an error executing `git stash`\n")
-	}
-}
-
-/*
- * Within the CWD, applies the latest stash.
+ * Pulls in changes from the client's remote and branch
+ * Returns error of git status and pull
  */
-func gitStashEnd() {
-	if stderr := exec.Command("git", "stash", "apply").Run(); stderr != nil {
-		fmt.Printf("\nThere was an error executing `git stash apply`\n")
+func pullDownConfigs(git Git) (error, []byte) {
+	pullStdout, pullStderr := git.pull()
+	if pullStderr != nil {
+		fmt.Printf("\n%s\n%v\n", pullStdout, pullStderr)
 	}
 
-	if stderr := exec.Command("git", "stash", "clear").Run(); stderr != nil {
-		fmt.Printf("\nThere was an error executing `git stash clear`\n")
-	}
+	return pullStderr, pullStdout
 }
 
 /*
- * Pulls in changes from origin/<currentBranch>
- * Returns error of git status and pull
+ * Pushes the client's branch to its remote and prints git's output.
  */
-func pullDownConfigs(dir string) (error, []byte) {
-	pullStdout, pullStderr := gitPull(dir)
-	if pullStderr != nil {
-		fmt.Printf("\n%s\n%v\n", pullStdout, pullStderr)
+func pushUpConfigs(git Git) ([]byte, error) {
+	fmt.Printf("\nUpdating Git from %s\n", git.dir)
+
+	stdout, stderr := git.push()
+	if stderr != nil {
+		fmt.Printf("\n%v\n", stderr)
+	} else {
+		fmt.Printf("%s", stdout)
 	}
 
-	return pullStderr, pullStdout
+	return stdout, stderr
 }
 
 /*
@@ -336,23 +653,132 @@ func replaceTildeInPath(path string) string {
 	return local_path
 }
 
-func main() {
-	flag.Parse()
+/*
+ * `configpp pull [config...]` pulls ConfigsSrc and copies the configs to their install paths.
+ * Afterwards, nvim configs whose lockfile changed have their plugins restored (see `resetNvim`),
+ * and the packages missing from this machine's package list are installed (see `syncPackages`),
+ * even when copying failed; every failure is returned.
+ */
+func runPull(names []string, options PullOptions) error {
+	if err := validateResolution(*FlagResolve); err != nil {
+		return err
+	}
 
-	if *FlagUpstream {
-		cpConfigs()
+	git, err := getConfigsGit()
+	if err != nil {
+		return err
+	}
 
-		if _, stderr := gitPush(ConfigsSrc); stderr != nil {
-			fmt.Fprintf(os.Stderr, "Error pushing to git: %v\n", stderr)
-		}
-	} else {
-		// Pull most recent changes from upstream (git)
-		pullStderr, _ := pullDownConfigs(ConfigsSrc)
-		if pullStderr != nil {
-			fmt.Fprintf(os.Stderr, "Errors pulling from git: %v\n", pullStderr)
+	// Copying from a branch the user did not choose would install the wrong configs
+	if err := git.checkBranch(); err != nil {
+		return err
+	}
+
+	// Pull most recent changes from upstream (git)
+	if *FlagDryRun {
+		reportDryRun(git.dir, "git", "pull", "--rebase", git.remote, git.branch)
+	} else if pullStderr, _ := pullDownConfigs(git); pullStderr != nil {
+		// Copying after a conflicted pull could install a config the user still has to merge
+		var conflict *PullConflictError
+		if errors.As(pullStderr, &conflict) {
+			return pullStderr
 		}
+		fmt.Fprintf(os.Stderr, "Errors pulling from git: %v\n", pullStderr)
+	}
+
+	// The manifest is loaded after pulling so changes to it are picked up
+	configs, err := loadConfigs(names)
+	if err != nil {
+		return err
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return fmt.Errorf("loading sync state: %w", err)
+	}
+	locks := hashNvimLocks(state, configs)
+
+	// NOTE: plugins and packages do not depend on every config copying, so a failed
+	// hook or a conflict does not hold them back
+	var errs []error
+	if err := cpConfigs(configs, false); err != nil {
+		errs = append(errs, fmt.Errorf("copying configs: %w", err))
+	}
+
+	resetNvims(configs, locks, options, *FlagDryRun)
+
+	if err := syncPackages(names, false); err != nil {
+		errs = append(errs, fmt.Errorf("installing packages: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+ * `configpp push [config...]` copies the installed configs into ConfigsSrc, captures this
+ * machine's packages into its package list (see `syncPackages`), commits the configs and
+ * package lists that changed (see `commitConfigs`), and pushes.
+ */
+func runPush(names []string, options CommitOptions) error {
+	if err := validateResolution(*FlagResolve); err != nil {
+		return err
+	}
+
+	configs, err := loadConfigs(names)
+	if err != nil {
+		return err
+	}
+
+	git, err := getConfigsGit()
+	if err != nil {
+		return err
+	}
+
+	// Checked before copying so nothing is left uncommitted on the wrong branch
+	if err := git.checkBranch(); err != nil {
+		return err
+	}
+
+	if err := cpConfigs(configs, true); err != nil {
+		return fmt.Errorf("copying configs: %w", err)
+	}
+
+	if err := syncPackages(names, true); err != nil {
+		return fmt.Errorf("capturing packages: %w", err)
+	}
+
+	if *FlagDryRun {
+		reportDryRun(git.dir, "git", "commit", "-m", "<message>", "--", "<changed configs>")
+		reportDryRun(git.dir, "git", "push", "-u", git.remote, git.branch)
+		return nil
+	}
+
+	// NOTE: the package lists are committed like a config
+	packages := Config{name: PackagesDir, dir: true, localDotfilesRepoPath: ConfigsSrc + "/" + PackagesDir}
+	if _, err := commitConfigs(git, append(configs, packages), options, os.Stdin, os.Stdout); err != nil {
+		return err
+	}
+
+	if commit, err := git.headCommit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read the commit of [%s]; it will not be recorded: %v\n", git.dir, err)
+	} else if err := recordPushCommit(configs, commit); err != nil {
+		fmt.Fprintf(os.Stderr, "Error recording sync state: %v\n", err)
+	}
+
+	// NOTE: pushes even when nothing was committed, in case earlier commits were never pushed
+	if _, stderr := pushUpConfigs(git); stderr != nil {
+		fmt.Fprintf(os.Stderr, "Error pushing to git: %v\n", stderr)
+	}
+
+	return nil
+}
+
+func main() {
+	flag.Usage = func() { printUsage(os.Stderr) }
+	flag.Parse()
 
-		cpConfigs()
-		deleteLocalShareNvim()
+	if err := runCLI(flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 }
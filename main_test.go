@@ -95,11 +95,30 @@ func gitCreateSandbox(callback func(dir string)) {
 	gitInitialCommit(localInstallPath)
 	// Push is required to create the "main" branch on the remote
 	// and set tracking from the local to the remote
-	gitPush(localInstallPath)
+	newGit(localInstallPath).push()
 
 	callback(localInstallPath)
 }
 
+/*
+ * Commits README.md with the provided content from a second clone of the provided
+ * directory's remote and pushes it, as if another machine pushed a change.
+ */
+func gitPushFromOtherMachine(dir string, content string) {
+	cloneDir, err := os.MkdirTemp("", "other_git_dir")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(cloneDir)
+
+	executeCommand(dir, "bash", "-c", "git clone \"$(git remote get-url origin)\" "+cloneDir)
+	gitLocalConfigDetails(cloneDir)
+	executeCommand(cloneDir, "bash", "-c", "echo "+content+" > README.md")
+	gitAddAll(cloneDir)
+	gitCommit(cloneDir)
+	executeCommand(cloneDir, "git", "push", "origin", "main")
+}
+
 /*
  * Modifies README.md, from the provided directory
  *
@@ -189,9 +208,7 @@ func TestChdir(t *testing.T) {
 }
 
 // TEST: Missing tests for cp'ing directories
-// TEST: Missing tests when the target of cp/rsync is missing the directory
-// being targeted (in both directions)
-// Lines 121-127 of main.go
+// NOTE: copying into missing directories is covered by TestSyncPath
 func TestCPConfig(t *testing.T) {
 	baseDest := "/tmp"
 	baseSrc := "/tmp/test"
@@ -203,17 +220,17 @@ func TestCPConfig(t *testing.T) {
 
 	happyPath := Config{
 		dir:                   false,
-		localInstallPath:      []string{localInstallPath, localInstallPath},
+		localInstallPath:      InstallPaths{DefaultInstallKey: localInstallPath},
 		localDotfilesRepoPath: localDotfilesRepoPath,
 	}
 	sadSrcPath := Config{
 		dir:                   false,
-		localInstallPath:      []string{localInstallPath, localInstallPath},
+		localInstallPath:      InstallPaths{DefaultInstallKey: localInstallPath},
 		localDotfilesRepoPath: "~/dev/configpp/does-not-exist.txt",
 	}
 	sadDestPath := Config{
 		dir:                   false,
-		localInstallPath:      []string{localInstallPath + "badddddddd", localInstallPath + "badddddddd"},
+		localInstallPath:      InstallPaths{DefaultInstallKey: localInstallPath + "badddddddd"},
 		localDotfilesRepoPath: localDotfilesRepoPath,
 	}
 
@@ -231,12 +248,12 @@ func TestCPConfig(t *testing.T) {
 
 	exec.Command("touch", localDotfilesRepoPath).Run()
 
-	stdout, stderr := cpConfig(happyPath, false)
+	_, stderr := cpConfig(happyPath, false, false)
 	if stderr != nil {
-		t.Errorf("There was an unexpected error copying:\n%s\n", stdout)
+		t.Errorf("There was an unexpected error copying:\n%v\n", stderr)
 	}
 
-	stdout, stderr = exec.Command("ls", path.Dir(happyPath.localInstallPath[0])).CombinedOutput()
+	stdout, stderr := exec.Command("ls", path.Dir(happyPath.localInstallPath[DefaultInstallKey])).CombinedOutput()
 	if stderr != nil {
 		t.Errorf("There was an unexpected error checking the test's destination:\n%s\n", stdout)
 	}
@@ -262,9 +279,9 @@ func TestCPConfig(t *testing.T) {
 
 	exec.Command("touch", localInstallPath).Run()
 
-	stdout, stderr = cpConfig(happyPath, true)
+	_, stderr = cpConfig(happyPath, true, false)
 	if stderr != nil {
-		t.Errorf("There was an unexpected error copying:\n%s\n", stdout)
+		t.Errorf("There was an unexpected error copying:\n%v\n", stderr)
 	}
 
 	stdout, stderr = exec.Command("ls", baseSrc).CombinedOutput()
@@ -291,7 +308,7 @@ func TestCPConfig(t *testing.T) {
 
 	exec.Command("touch", localDotfilesRepoPath).Run()
 
-	_, stderr = cpConfig(sadSrcPath, true)
+	_, stderr = cpConfig(sadSrcPath, true, false)
 	if stderr == nil {
 		t.Errorf("Expected an error copying with a bad src filepath")
 	}
@@ -311,7 +328,7 @@ func TestCPConfig(t *testing.T) {
 
 	exec.Command("touch", localInstallPath).Run()
 
-	_, stderr = cpConfig(sadDestPath, false)
+	_, stderr = cpConfig(sadDestPath, false, false)
 	if stderr == nil {
 		t.Errorf("Expected an error copying with a bad dest filepath")
 	}
@@ -321,239 +338,225 @@ func TestCPConfig(t *testing.T) {
 	executeCommand("/tmp", "rm", "-rf", "test")
 }
 
-// TEST: test for createMissingTargetDirectory
-func TestCreateMissingTargetDirectory(t *testing.T) {}
+func TestCPConfigsMissingBinary(t *testing.T) {
+	configsSrc := configsSandbox(t, "")
+	os.MkdirAll(path.Join(configsSrc, "ghostty"), 0755)
+	os.WriteFile(path.Join(configsSrc, "ghostty", "config"), []byte("font-size = 14\n"), 0644)
+
+	installDir := path.Join(t.TempDir(), "ghostty")
+	config := Config{
+		name:                  "ghostty",
+		dir:                   true,
+		localInstallPath:      InstallPaths{DefaultInstallKey: installDir},
+		localDotfilesRepoPath: path.Join(configsSrc, "ghostty"),
+		binary:                "configpp-missing-binary",
+	}
+
+	// Configs of programs that are not installed are skipped
+	if err := cpConfigs([]Config{config}, false); err != nil || pathExists(installDir) {
+		t.Errorf("Expected the config to be skipped; err: %v", err)
+	}
+
+	// -force installs them anyway
+	*FlagForce = true
+	defer func() { *FlagForce = false }()
+	if err := cpConfigs([]Config{config}, false); err != nil || !pathExists(path.Join(installDir, "config")) {
+		t.Errorf("Expected the config to be installed with -force; err: %v", err)
+	}
+
+	// Skipped configs cannot stop the run with their conflicts
+	*FlagForce = false
+	conflictSandbox(t, func(config Config, state SyncState) {
+		saveState(state)
+		executeCommand(config.localDotfilesRepoPath, "bash", "-c", "echo repo > init.lua")
+		executeCommand(config.localInstallPath[DefaultInstallKey], "bash", "-c", "echo local > init.lua")
+
+		config.binary = "configpp-missing-binary"
+		if err := cpConfigs([]Config{config}, false); err != nil {
+			t.Errorf("Expected the conflicting config to be skipped without an error; err: %v", err)
+		}
+	})
+
+	// Installed binaries are found on $PATH
+	config.binary = "sh"
+	if missingBinary(config) {
+		t.Errorf("Expected sh to be installed")
+	}
+}
 
 func TestPullDownConfigs(t *testing.T) {
 	// Happy path - clean working tree, so there's no chance for errors
 	fmt.Printf("\n\nTestGetConfigs: Happy Path\n\n")
 
 	gitCreateSandbox(func(dir string) {
-		pullStderr, _ := pullDownConfigs(dir)
+		pullStderr, _ := pullDownConfigs(newGit(dir))
 
 		if pullStderr != nil {
 			t.Error("There was a git pull error", pullStderr)
 		}
 	})
 
-	// Sad path
-	// 1. fails if working tree is dirty
-	// 2. up-to-date with remote
-	fmt.Printf("\n\nTestGetConfigs: Sad Path\n\n")
+	// Happy path - a dirty working tree is stashed around the pull and restored
+	fmt.Printf("\n\nTestGetConfigs: Dirty Working Tree\n\n")
 
 	gitCreateSandbox(func(dir string) {
-		// Better to clean up in case of a previously failed run
-		// than to assume this test case never fails
-		gitCleanWorkingTree(dir)
-
-		// 1: Working tree is dirty
-		// 1: Working tree is dirty
-		// 1: Working tree is dirty
-
-		// Status errors can be triggered by dirtying the working tree
-		// before attempting to pull
+		gitPushFromOtherMachine(dir, "remote")
 		gitDirtyRepoWithUntrackedChange(dir)
 		gitAddAll(dir)
 
-		pullStderr, _ := pullDownConfigs(dir)
-
-		if pullStderr != nil && pullStderr.Error() != "exit status 128" {
-			t.Error("Expected pull errors when pulling from remote with a dirty working tree")
-		}
-
-		// 2: up-to-date with remote
-		// 2: up-to-date with remote
-		// 2: up-to-date with remote
+		pullStderr, _ := pullDownConfigs(newGit(dir))
 
-		gitCleanWorkingTree(dir)
-
-		pullStderr, pullStdout := pullDownConfigs(dir)
-		pullStdoutContains := strings.Contains(string(pullStdout), "Already up to date.")
-
-		if pullStderr != nil || !pullStdoutContains {
-			t.Error("Expected 'Already up to date.' stdout pulling from remote while already up-to-date")
+		if pullStderr != nil {
+			t.Error("There was a git pull error pulling with a dirty working tree", pullStderr)
 		}
-	})
-}
-
-func TestGetGitStatus(t *testing.T) {
-	// Happy path - output contains "nothing to commit, working tree clean"
-
-	fmt.Printf("\n\nTestGetConfigs: Happy Path\n\n")
-
-	gitCreateSandbox(func(dir string) {
-		stdout, stderr := gitStatus(dir)
 
-		if stderr != nil {
-			t.Error("Expected no errors from gitStatus")
+		readme, _ := os.ReadFile(path.Join(dir, "README.md"))
+		if string(readme) != "remote\n" || !pathExists(path.Join(dir, "test.txt")) {
+			t.Errorf("Expected the pulled README.md and the restored test.txt; README.md: %q", readme)
 		}
 
-		if !strings.Contains(string(stdout), "nothing to commit, working tree clean") {
-			t.Error("Expected stdout to contain, 'nothing to commit, working tree clean'")
+		if stashes, _ := newGit(dir).output("stash", "list"); len(stashes) != 0 {
+			t.Errorf("Expected the stash to be popped; stashes: %s", stashes)
 		}
 	})
 
-	// Sad path
-	// 1. Output contains "Changes not staged for commit:"
-	// 2. Output contains "Changes to be committed"
-	// 3. Output contains "Untracked files"
-	// 4. Error contains "fatal: not a git repository"
-
-	// 1. Output contains "Changes not staged for commit:"
-	gitCreateSandbox(func(dir string) {
-		gitDirtyRepoWithTrackedChange(dir)
-
-		stdout, stderr := gitStatus(dir)
-
-		if stderr != nil {
-			t.Error("Expected no errors from gitStatus")
-		}
-
-		if !strings.Contains(string(stdout), "Changes not staged for commit:") {
-			t.Error("Expected stdout to contain, 'Changes not staged for commit:'")
-		}
-	})
+	// Sad path - up-to-date with remote
+	fmt.Printf("\n\nTestGetConfigs: Sad Path\n\n")
 
-	// 2. Output contains "Changes to be committed"
 	gitCreateSandbox(func(dir string) {
-		gitDirtyRepoWithTrackedChange(dir)
-		gitAddAll(dir)
-
-		stdout, stderr := gitStatus(dir)
+		// Better to clean up in case of a previously failed run
+		// than to assume this test case never fails
+		gitCleanWorkingTree(dir)
 
-		if stderr != nil {
-			t.Error("Expected no errors from gitStatus")
-		}
+		pullStderr, pullStdout := pullDownConfigs(newGit(dir))
+		pullStdoutContains := strings.Contains(string(pullStdout), "Already up to date.")
 
-		if !strings.Contains(string(stdout), "Changes to be committed") {
-			t.Error("Expected stdout to contain, 'Changes to be committed'")
+		if pullStderr != nil || !pullStdoutContains {
+			t.Error("Expected 'Already up to date.' stdout pulling from remote while already up-to-date")
 		}
 	})
+}
 
-	// 3. Output contains "Untracked files"
-	gitCreateSandbox(func(dir string) {
-		gitDirtyRepoWithUntrackedChange(dir)
-
-		stdout, stderr := gitStatus(dir)
-
-		if stderr != nil {
-			t.Error("Expected no errors from gitStatus")
-		}
-
-		if !strings.Contains(string(stdout), "Untracked files") {
-			t.Error("Expected stdout to contain, 'Untracked files'")
-		}
-	})
+func TestGetConfigsGit(t *testing.T) {
+	configsSrc := configsSandbox(t, "")
+	defer func() { *FlagRemote, *FlagBranch, *FlagProfile = "", "", "" }()
 
-	// 4. Error contains "fatal: not a git repository"
-	chdir("/tmp")
+	// Without a manifest, the defaults
+	git, err := getConfigsGit()
+	if err != nil || git.dir != configsSrc || git.remote != DefaultRemote || git.branch != DefaultBranch {
+		t.Errorf("Expected the default remote and branch; got %+v (%v)", git, err)
+	}
 
-	stdout, _ := gitStatus("/tmp")
+	// The manifest overrides the defaults
+	os.WriteFile(path.Join(configsSrc, ManifestFile), []byte(`{"remote": "github", "branch": "dotfiles", "configs": [{"name": "nvim", "repo": "nvim", "install": "/tmp/nvim"}]}`), 0644)
+	git, err = getConfigsGit()
+	if err != nil || git.remote != "github" || git.branch != "dotfiles" {
+		t.Errorf("Expected the manifest's remote and branch; got %+v (%v)", git, err)
+	}
 
-	if !strings.Contains(string(stdout), "fatal: not a git repository") {
-		t.Error("Expected stdout to contain, 'fatal: not a git repository'")
+	// The profile overrides the manifest
+	os.WriteFile(path.Join(configsSrc, ManifestFile), []byte(`{"remote": "github", "branch": "dotfiles", "profiles": {"work": {"branch": "work"}}, "configs": [{"name": "nvim", "repo": "nvim", "install": "/tmp/nvim"}]}`), 0644)
+	*FlagProfile = "work"
+	git, err = getConfigsGit()
+	if err != nil || git.remote != "github" || git.branch != "work" {
+		t.Errorf("Expected the profile's branch to override the manifest; got %+v (%v)", git, err)
 	}
-}
 
-func TestGetLocalDirIndex(t *testing.T) {
-	index := getLocalDirIndex()
+	// The flags override the profile
+	*FlagBranch = "laptop"
+	git, err = getConfigsGit()
+	if err != nil || git.remote != "github" || git.branch != "laptop" {
+		t.Errorf("Expected the -branch flag to override the profile; got %+v (%v)", git, err)
+	}
+	*FlagProfile = ""
 
-	if OS == "darwin" {
-		if index != 0 {
-			t.Error("Incorrect index returned for Mac devices")
-		}
-	} else if OS == "linux" {
-		if index != 1 {
-			t.Error("Incorrect index returned for Linux devices")
-		}
-	} else {
-		if index != 2 {
-			t.Error("Incorrect index returned for Other devices")
-		}
+	// Sad path - invalid names
+	os.WriteFile(path.Join(configsSrc, ManifestFile), []byte(`{"remote": "--upload-pack=x", "configs": [{"name": "nvim", "repo": "nvim", "install": "/tmp/nvim"}]}`), 0644)
+	if _, err := getConfigsGit(); err == nil {
+		t.Errorf("Expected an error for an invalid remote")
 	}
 }
 
 // Ghostty installs in a different location in Mac OSX
 func TestGetOSSpecificDestinationPath(t *testing.T) {
-	path := getOSSpecificDestionationPath(Ghostty)
+	type DestinationTest struct {
+		config Config
+		expect string
+		err    bool
+	}
+
+	tests := []DestinationTest{
+		// Only a fallback
+		{config: Config{localInstallPath: InstallPaths{DefaultInstallKey: "/default"}}, expect: "/default"},
+		// OS beats the fallback
+		{config: Config{localInstallPath: InstallPaths{OS: "/os", DefaultInstallKey: "/default"}}, expect: "/os"},
+		// OS and architecture beat OS
+		{config: Config{localInstallPath: InstallPaths{OS + "/" + Arch: "/arch", OS: "/os"}}, expect: "/arch"},
+		// Hostname beats everything
+		{config: Config{localInstallPath: InstallPaths{"host:" + getHostname(): "/host", OS + "/" + Arch: "/arch"}}, expect: "/host"},
+		// Nothing matches, such as Ghostty's darwin/linux paths on freebsd
+		{config: Config{localInstallPath: InstallPaths{"plan9": "/plan9"}}, err: true},
+	}
+
+	for _, test := range tests {
+		path, err := getOSSpecificDestionationPath(test.config)
+
+		if test.err {
+			if err == nil {
+				t.Errorf("Expected an error when no install path matches; got (%s)", path)
+			}
+			continue
+		}
 
-	if path != Ghostty.localInstallPath[getLocalDirIndex()] {
-		t.Errorf("Path received (%s) was not as expected (%s)", path, Ghostty.localInstallPath[getLocalDirIndex()])
+		if err != nil || path != test.expect {
+			t.Errorf("Path received (%s) was not as expected (%s); err: %v", path, test.expect, err)
+		}
 	}
 }
 
-func TestGetRsyncPaths(t *testing.T) {
-	type RsyncTest struct {
+func TestGetSyncPaths(t *testing.T) {
+	type SyncPathsTest struct {
 		config   Config
 		expect   string
 		target   string
 		upstream bool
 	}
 
-	tests := []RsyncTest{
-		// TEST: If copying upstream && config is a directory, dest == config localDotfilesRepoPath including (copying the contents of src into dest)
+	tests := []SyncPathsTest{
+		// TEST: If copying upstream && config is a directory, dest == config localDotfilesRepoPath
 		{config: Alacritty, upstream: true, target: "dest", expect: Alacritty.localDotfilesRepoPath},
-		// TEST: If copying upstream && config is not a directory, dest == configs root directory (copying local file to config dir root)
+		// TEST: If copying upstream && config is not a directory, dest == config localDotfilesRepoPath (the file itself)
 		{config: Vim, upstream: true, target: "dest", expect: Vim.localDotfilesRepoPath},
-		// TEST: If copying upstream && config is a directory, src == config.localInstallPath + "/" (rsync only copies dir contents if dir ends "/")
-		{config: Alacritty, upstream: true, target: "src", expect: getOSSpecificDestionationPath(Alacritty) + "/"},
-		// TEST: If copying downstream && config is a directory, dest == config localInstallPath - "config name"
-		{config: Alacritty, upstream: false, target: "dest", expect: path.Dir(getOSSpecificDestionationPath(Alacritty))},
+		// TEST: If copying upstream && config is a directory, src == config.localInstallPath without a trailing "/"
+		{config: Alacritty, upstream: true, target: "src", expect: Alacritty.localInstallPath[DefaultInstallKey]},
+		// TEST: If copying downstream && config is a directory, dest == config localInstallPath (the directory itself)
+		{config: Alacritty, upstream: false, target: "dest", expect: Alacritty.localInstallPath[DefaultInstallKey]},
+		// TEST: If copying downstream && config is not a directory, dest == config localInstallPath (the file itself)
+		{config: Vim, upstream: false, target: "dest", expect: Vim.localInstallPath[DefaultInstallKey]},
 		// TEST: If copying downstram && config is a directory, src == config's localDotfilesRepoPath in configs dir
 		{config: Alacritty, upstream: false, target: "src", expect: Alacritty.localDotfilesRepoPath},
 	}
 
 	for _, v := range tests {
-		dest, src := getRsyncPaths(v.config, v.upstream)
+		dest, src, err := getSyncPaths(v.config, v.upstream)
+		if err != nil {
+			t.Errorf("Unexpected error getting sync paths: %v", err)
+		}
 
 		if v.target == "dest" {
 			if dest != v.expect {
-				t.Errorf("Rsync destination path (%s) not as expected (%s)", dest, v.expect)
+				t.Errorf("Sync destination path (%s) not as expected (%s)", dest, v.expect)
 			}
 		}
 
 		if v.target == "src" {
 			if src != v.expect {
-				t.Errorf("Rsync source path (%s) not as expected (%s)", src, v.expect)
+				t.Errorf("Sync source path (%s) not as expected (%s)", src, v.expect)
 			}
 		}
 	}
 }
 
-func TestPullFromGit(t *testing.T) {
-	gitCreateSandbox(func(dir string) {
-		_, stderr := gitPull(dir)
-		if stderr != nil {
-			t.Errorf("There was an unexpected error from gitPull(): [%s]", stderr.Error())
-		}
-	})
-
-	// Sad path - create uncommitted changes to dirty the working tree to prevent pulling
-
-	gitCreateSandbox(func(dir string) {
-		gitDirtyRepoWithTrackedChange(dir)
-		gitAddAll(dir)
-
-		stdout, _ := gitPull(dir)
-		if !strings.Contains(string(stdout), "Your index contains uncommitted changes") {
-			t.Errorf("Error containing 'Your index contains uncommitted changes' expected")
-		}
-	})
-}
-
-func TestPushToGit(t *testing.T) {
-	gitCreateSandbox(func(dir string) {
-		gitDirtyRepoWithUntrackedChange(dir)
-		gitAddAll(dir)
-		gitCommit(dir)
-
-		_, stderr := gitPush(dir)
-		if stderr != nil {
-			t.Errorf("Git Push failed; %v", stderr)
-		}
-	})
-}
-
 func TestGetHomePath(t *testing.T) {
 	if OS == "darwin" {
 		expect := "/Users/" + USERNAME
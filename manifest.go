@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ManifestFile is the name of the manifest looked up in ConfigsSrc
+const ManifestFile = "configpp.json"
+
+/*
+ * Manifest
+ *
+ * The declarative description of every config configpp syncs. It is stored as JSON
+ * (ConfigsSrc/configpp.json by default) so adding a tool does not require recompiling:
+ *
+ *	{
+ *	  "configs": [
+ *	    { "name": "nvim", "dir": true, "repo": "nvim", "install": "~/.config/nvim" },
+ *	    { "name": "ghostty", "dir": true, "repo": "ghostty", "install": { "darwin": "~/Library/Application Support/com.mitchellh.ghostty", "linux": "~/.config/ghostty" } }
+ *	  ]
+ *	}
+ *
+ * `repo` is relative to ConfigsSrc unless it is absolute or starts with "~".
+ * `install` is either a single path, used on every machine, or an object keyed like `InstallPaths`.
+ * `include` and `exclude` are optional gitignore-style patterns, such as "lazy-lock.json" or "*.swp".
+ * `mode` is "copy" (the default) or "symlink", which links the install path to the repo path instead.
+ * `hooks` are optional commands run before and after a sync that changes the config (see `Hook`).
+ * `type` is "files" (the default), "nvim", which restores plugins when a pull changes lazy-lock.json,
+ * or "fonts", which installs the repo's fonts into the OS font directory (see `syncFonts`).
+ * `binary` is the optional program the config belongs to, such as "ghostty"; pulls skip the config
+ * on machines where it is not on $PATH, unless `-force` is passed.
+ * `remote` and `branch` are optional, and set what ConfigsSrc pulls from and pushes to
+ * (see `getConfigsGit`).
+ * `variables` are optional values shared by every machine, and `profiles` are optional
+ * per-machine narrowings and overrides of the configs (see `Profile`).
+ */
+type Manifest struct {
+	Remote    string             `json:"remote,omitempty"`
+	Branch    string             `json:"branch,omitempty"`
+	Variables map[string]string  `json:"variables,omitempty"`
+	Profiles  map[string]Profile `json:"profiles,omitempty"`
+	Configs   []ManifestEntry    `json:"configs"`
+}
+
+type ManifestEntry struct {
+	Name    string       `json:"name"`
+	Dir     bool         `json:"dir"`
+	Repo    string       `json:"repo"`
+	Install InstallPaths `json:"install"`
+	Include []string     `json:"include,omitempty"`
+	Exclude []string     `json:"exclude,omitempty"`
+	Mode    string       `json:"mode,omitempty"`
+	Hooks   ConfigHooks  `json:"hooks,omitempty"`
+	Type    string       `json:"type,omitempty"`
+	Binary  string       `json:"binary,omitempty"`
+}
+
+// Values of a config's `type`
+const (
+	TypeFiles = "files"
+	TypeFonts = "fonts"
+	TypeNvim  = "nvim"
+)
+
+// GOOS values accepted in `install` keys
+var knownOS = []string{"aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios", "js", "linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows"}
+
+/*
+ * Writes an install path used on every machine back as a plain string.
+ */
+func (installPaths InstallPaths) MarshalJSON() ([]byte, error) {
+	if single, ok := installPaths[DefaultInstallKey]; ok && len(installPaths) == 1 {
+		return json.Marshal(single)
+	}
+
+	return json.Marshal(map[string]string(installPaths))
+}
+
+/*
+ * Allows `install` to be a plain string, which is shorthand for {"default": "<path>"}.
+ */
+func (installPaths *InstallPaths) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*installPaths = InstallPaths{DefaultInstallKey: single}
+		return nil
+	}
+
+	var keyed map[string]string
+	if err := json.Unmarshal(data, &keyed); err != nil {
+		return errors.New("\"install\" must be a path or an object of paths")
+	}
+	*installPaths = keyed
+
+	return nil
+}
+
+/*
+ * Converts a manifest entry into a Config, expanding "~" and resolving the repo
+ * path against ConfigsSrc.
+ */
+func (entry ManifestEntry) toConfig() Config {
+	install := entry.Install
+	if entry.Type == TypeFonts && len(install) == 0 {
+		install = FontInstallPaths
+	}
+
+	installPaths := InstallPaths{}
+	for key, installPath := range install {
+		installPaths[key] = replaceTildeInPath(installPath)
+	}
+
+	return Config{
+		name:                  entry.Name,
+		dir:                   entry.Dir,
+		localInstallPath:      installPaths,
+		localDotfilesRepoPath: resolveRepoPath(entry.Repo),
+		include:               entry.Include,
+		exclude:               entry.Exclude,
+		mode:                  entry.Mode,
+		hooks:                 entry.Hooks,
+		kind:                  entry.Type,
+		binary:                entry.Binary,
+	}
+}
+
+/*
+ * Returns the configs described by the manifest at the provided path.
+ *
+ * If the manifest does not exist, the built-in `Configs` are returned so configpp
+ * keeps working on machines that have never written a manifest.
+ */
+func loadManifest(manifestPath string) ([]Config, error) {
+	manifest, err := readManifest(manifestPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Configs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]Config, len(manifest.Configs))
+	for i, entry := range manifest.Configs {
+		configs[i] = entry.toConfig()
+		configs[i].variables = manifest.Variables
+	}
+
+	return configs, nil
+}
+
+/*
+ * Decodes and validates a manifest. Unknown keys are rejected so typos such as
+ * "instal" fail loudly instead of silently dropping a path.
+ */
+func parseManifest(reader io.Reader) (Manifest, error) {
+	var manifest Manifest
+
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	return manifest, validateManifest(manifest)
+}
+
+/*
+ * Returns every problem found in the manifest joined into a single error, or nil.
+ */
+func validateManifest(manifest Manifest) error {
+	var errs []error
+
+	if len(manifest.Configs) == 0 {
+		errs = append(errs, errors.New("manifest does not declare any configs"))
+	}
+	errs = append(errs, validateRemoteAndBranch("manifest", manifest.Remote, manifest.Branch)...)
+
+	seen := map[string]bool{}
+	for i, entry := range manifest.Configs {
+		label := fmt.Sprintf("config #%d (%q)", i+1, entry.Name)
+
+		if entry.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: missing \"name\"", label))
+		} else if strings.ContainsAny(entry.Name, " ,/") {
+			errs = append(errs, fmt.Errorf("%s: \"name\" cannot contain spaces, commas, or slashes", label))
+		} else if seen[entry.Name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate name", label))
+		}
+		seen[entry.Name] = true
+
+		if entry.Repo == "" {
+			errs = append(errs, fmt.Errorf("%s: missing \"repo\"", label))
+		}
+
+		if _, err := newIgnoreMatcher(entry.Include, entry.Exclude); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", label, err))
+		}
+
+		if entry.Mode != "" && entry.Mode != ModeCopy && entry.Mode != ModeSymlink {
+			errs = append(errs, fmt.Errorf("%s: \"mode\" must be %q or %q", label, ModeCopy, ModeSymlink))
+		}
+
+		if entry.Type != "" && entry.Type != TypeFiles && entry.Type != TypeFonts && entry.Type != TypeNvim {
+			errs = append(errs, fmt.Errorf("%s: \"type\" must be %q, %q, or %q", label, TypeFiles, TypeFonts, TypeNvim))
+		}
+		if entry.Type == TypeFonts && entry.Mode == ModeSymlink {
+			errs = append(errs, fmt.Errorf("%s: fonts are installed into a shared directory, so they cannot be symlinked", label))
+		}
+
+		// NOTE: fonts default to the OS font directory
+		if entry.Type != TypeFonts || len(entry.Install) > 0 {
+			errs = append(errs, validateInstallPaths(label, entry.Install)...)
+		}
+		errs = append(errs, validateHooks(label, entry.Hooks)...)
+	}
+
+	errs = append(errs, validateProfiles(manifest)...)
+
+	return errors.Join(errs...)
+}
+
+/*
+ * Returns every problem with a remote and branch, prefixing each with `label`. A leading
+ * "-" would be read by git as a flag.
+ */
+func validateRemoteAndBranch(label string, remote string, branch string) []error {
+	var errs []error
+
+	for _, field := range [][2]string{{"remote", remote}, {"branch", branch}} {
+		if strings.HasPrefix(field[1], "-") || strings.ContainsAny(field[1], " \t") {
+			errs = append(errs, fmt.Errorf("%s: %q is not a valid %q", label, field[1], field[0]))
+		}
+	}
+
+	return errs
+}
+
+/*
+ * Returns every problem with a set of install paths, prefixing each with `label`.
+ */
+func validateInstallPaths(label string, installPaths InstallPaths) []error {
+	var errs []error
+
+	if len(installPaths) == 0 {
+		errs = append(errs, fmt.Errorf("%s: missing \"install\"", label))
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(installPaths)) {
+		if err := validateInstallKey(key); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", label, err))
+		}
+		if installPath := installPaths[key]; !path.IsAbs(replaceTildeInPath(installPath)) {
+			errs = append(errs, fmt.Errorf("%s: install path %q must be absolute or start with \"~\"", label, installPath))
+		}
+	}
+
+	return errs
+}
+
+/*
+ * Returns an error when an `install` key is not one of the forms documented on `InstallPaths`.
+ */
+func validateInstallKey(key string) error {
+	if key == DefaultInstallKey {
+		return nil
+	}
+
+	if hostname, ok := strings.CutPrefix(key, "host:"); ok {
+		if hostname == "" {
+			return fmt.Errorf("install key %q is missing a hostname", key)
+		}
+		return nil
+	}
+
+	goos, goarch, hasArch := strings.Cut(key, "/")
+	if !slices.Contains(knownOS, goos) {
+		return fmt.Errorf("install key %q is not \"default\", \"host:<hostname>\", or a known GOOS (%s)", key, strings.Join(knownOS, ", "))
+	}
+	if hasArch && goarch == "" {
+		return fmt.Errorf("install key %q is missing an architecture", key)
+	}
+
+	return nil
+}
+
+/*
+ * Returns the manifest at the provided path. The error wraps `fs.ErrNotExist` when
+ * there is no manifest.
+ */
+func readManifest(manifestPath string) (Manifest, error) {
+	file, err := os.Open(replaceTildeInPath(manifestPath))
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer file.Close()
+
+	manifest, err := parseManifest(file)
+	if err != nil {
+		return manifest, fmt.Errorf("%s: %w", manifestPath, err)
+	}
+
+	return manifest, nil
+}
+
+/*
+ * Replaces a path starting with your local $HOME path variable value with "~", so the
+ * manifest works for every user.
+ */
+func replaceHomeWithTilde(filePath string) string {
+	if rest, ok := strings.CutPrefix(filePath, getHomePath()); ok && (rest == "" || strings.HasPrefix(rest, "/")) {
+		return "~" + rest
+	}
+
+	return filePath
+}
+
+/*
+ * Resolves a manifest repo path: "~" is expanded, absolute paths are kept, and
+ * everything else is relative to ConfigsSrc.
+ */
+func resolveRepoPath(repoPath string) string {
+	expanded := replaceTildeInPath(repoPath)
+	if path.IsAbs(expanded) {
+		return path.Clean(expanded)
+	}
+
+	return path.Join(ConfigsSrc, expanded)
+}
+
+func saveManifest(manifestPath string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(replaceTildeInPath(manifestPath), append(data, '\n'), 0644)
+}
+
+/*
+ * Converts a Config back into a manifest entry, the reverse of `toConfig`.
+ */
+func toManifestEntry(config Config) ManifestEntry {
+	repo := replaceHomeWithTilde(config.localDotfilesRepoPath)
+	if rel, ok := strings.CutPrefix(config.localDotfilesRepoPath, ConfigsSrc+"/"); ok {
+		repo = rel
+	}
+
+	installPaths := InstallPaths{}
+	for key, installPath := range config.localInstallPath {
+		installPaths[key] = replaceHomeWithTilde(installPath)
+	}
+
+	return ManifestEntry{
+		Name:    config.name,
+		Dir:     config.dir,
+		Repo:    repo,
+		Install: installPaths,
+		Include: config.include,
+		Exclude: config.exclude,
+		Mode:    config.mode,
+		Hooks:   config.hooks,
+		Type:    config.kind,
+		Binary:  config.binary,
+	}
+}
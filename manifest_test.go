@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	dir, err := os.MkdirTemp("", "manifest_dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Missing manifest falls back to the built-in configs
+	configs, err := loadManifest(dir + "/" + ManifestFile)
+	if err != nil {
+		t.Errorf("Expected no error loading a missing manifest; err: %v", err)
+	}
+	if len(configs) != len(Configs) {
+		t.Errorf("Expected the built-in configs (%d) when the manifest is missing; got %d", len(Configs), len(configs))
+	}
+
+	// Valid manifest
+	manifest := `{"configs": [{"name": "nvim", "dir": true, "repo": "nvim", "install": "~/.config/nvim"}]}`
+	if err := os.WriteFile(dir+"/"+ManifestFile, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err = loadManifest(dir + "/" + ManifestFile)
+	if err != nil {
+		t.Fatalf("Expected no error loading a valid manifest; err: %v", err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("Expected 1 config; got %d", len(configs))
+	}
+	if configs[0].localDotfilesRepoPath != ConfigsSrc+"/nvim" {
+		t.Errorf("Repo path (%s) was not resolved against ConfigsSrc", configs[0].localDotfilesRepoPath)
+	}
+	if configs[0].localInstallPath[DefaultInstallKey] != getHomePath()+"/.config/nvim" {
+		t.Errorf("Install path (%s) did not have its tilde replaced", configs[0].localInstallPath[DefaultInstallKey])
+	}
+
+	// Fonts install into the OS font directory by default
+	manifest = `{"configs": [{"name": "fonts", "dir": true, "repo": "fonts", "type": "fonts"}]}`
+	if err := os.WriteFile(dir+"/"+ManifestFile, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err = loadManifest(dir + "/" + ManifestFile)
+	if err != nil {
+		t.Fatalf("Expected no error loading a fonts config without an install path; err: %v", err)
+	}
+	if configs[0].localInstallPath["linux"] != getHomePath()+"/.local/share/fonts" {
+		t.Errorf("Install paths (%v) not the OS font directories", configs[0].localInstallPath)
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	tests := []InputOutput{
+		{input: `{"configs": []}`, output: "does not declare any configs"},
+		{input: `{"configs": [{"name": "nvim", "instal": "~/.config/nvim"}]}`, output: "unknown field"},
+		{input: `{"configs": [{"dir": true, "repo": "nvim", "install": "~/.config/nvim"}]}`, output: `missing "name"`},
+		{input: `{"configs": [{"name": "nvim", "install": "~/.config/nvim"}]}`, output: `missing "repo"`},
+		{input: `{"configs": [{"name": "nvim", "repo": "nvim"}]}`, output: `missing "install"`},
+		{input: `{"configs": [{"name": "nvim", "repo": "nvim", "install": ".config/nvim"}]}`, output: "must be absolute"},
+		{input: `{"configs": [{"name": "nvim", "repo": "nvim", "install": {"linx": "~/.config/nvim"}}]}`, output: "known GOOS"},
+		{input: `{"configs": [{"name": "nvim", "repo": "nvim", "install": ["~/.config/nvim"]}]}`, output: "must be a path or an object"},
+		{input: `{"configs": [{"name": "vim", "repo": "vim", "install": "~/.vimrc"}, {"name": "vim", "repo": "vim", "install": "~/.vimrc"}]}`, output: "duplicate name"},
+		{input: `{"configs": [{"name": "fonts", "repo": "fonts", "type": "font"}]}`, output: `"type" must be`},
+		{input: `{"configs": [{"name": "fonts", "repo": "fonts", "type": "fonts", "mode": "symlink"}]}`, output: "cannot be symlinked"},
+	}
+
+	for _, test := range tests {
+		_, err := parseManifest(strings.NewReader(test.input))
+
+		if err == nil || !strings.Contains(err.Error(), test.output) {
+			t.Errorf("Expected error containing %q; manifest: %s; err: %v", test.output, test.input, err)
+		}
+	}
+}
+
+func TestToManifestEntry(t *testing.T) {
+	// Every built-in config survives a round trip through the manifest
+	for _, config := range Configs {
+		entry := toManifestEntry(config)
+		if strings.HasPrefix(entry.Repo, "/") {
+			t.Errorf("Expected %s's repo path (%s) to be relative to ConfigsSrc", config.name, entry.Repo)
+		}
+
+		roundTrip := entry.toConfig()
+		if roundTrip.localDotfilesRepoPath != config.localDotfilesRepoPath || len(roundTrip.localInstallPath) != len(config.localInstallPath) {
+			t.Errorf("Round trip of %s (%+v) not as expected", config.name, roundTrip)
+		}
+		for key, installPath := range config.localInstallPath {
+			if roundTrip.localInstallPath[key] != installPath {
+				t.Errorf("Round trip of %s's %s install path (%s) not as expected", config.name, key, roundTrip.localInstallPath[key])
+			}
+		}
+	}
+}
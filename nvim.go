@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+)
+
+// Lockfile lazy.nvim pins every plugin's commit in, at the root of an nvim config
+const LazyLockFile = "lazy-lock.json"
+
+/*
+ * PullOptions
+ *
+ * `purgeNvim` removes the data directory of nvim configs (see `getNvimDataPath`) before
+ * their plugins are restored, for when a plugin build or Mason tool is beyond repair.
+ */
+type PullOptions struct {
+	purgeNvim bool
+}
+
+/*
+ * Returns nvim's data directory, $XDG_DATA_HOME/nvim, defaulting to ~/.local/share/nvim.
+ */
+func getNvimDataPath() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = path.Join(getHomePath(), ".local", "share")
+	}
+
+	return path.Join(dataHome, "nvim")
+}
+
+/*
+ * Returns the SHA-256 of the lockfile installed by each nvim config, keyed by config
+ * name, so `resetNvims` can tell which lockfiles a pull changed. A symlinked config's
+ * lockfile is the repo's, which the pull already changed, so the hash recorded by its
+ * last sync is used instead.
+ */
+func hashNvimLocks(state SyncState, configs []Config) map[string]string {
+	hashes := map[string]string{}
+
+	for _, config := range configs {
+		if config.kind != TypeNvim {
+			continue
+		}
+
+		if config.mode == ModeSymlink {
+			hashes[config.name] = state.config(config.name).Files[LazyLockFile]
+			continue
+		}
+
+		// NOTE: a config without an install path is never synced, so its hash is never compared
+		installPath, _ := getOSSpecificDestionationPath(config)
+		hashes[config.name] = hashLazyLock(path.Join(installPath, LazyLockFile))
+	}
+
+	return hashes
+}
+
+/*
+ * Returns the SHA-256 of a lockfile, or "" when there is none.
+ */
+func hashLazyLock(lockPath string) string {
+	content, err := os.ReadFile(lockPath)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(content)
+
+	return hex.EncodeToString(hash[:])
+}
+
+/*
+ * Brings the plugins of an nvim config in line with its lockfile after a pull, instead
+ * of wiping nvim's data directory and re-downloading everything.
+ *
+ * When the pull changed the installed lockfile (compared with `before`, from
+ * `hashNvimLocks`), `Lazy! restore` runs in a headless nvim, checking out the locked
+ * commit of every plugin and installing missing ones. The data directory, which also
+ * holds Mason tools and undo history, is only removed with `options.purgeNvim`.
+ */
+func resetNvim(config Config, before string, options PullOptions, dryRun bool) error {
+	installPath, err := getOSSpecificDestionationPath(config)
+	if err != nil {
+		return err
+	}
+
+	// NOTE: a dry run copied nothing, so the lockfile the pull would install is the repo's
+	lockPath := path.Join(installPath, LazyLockFile)
+	if dryRun {
+		lockPath = path.Join(config.localDotfilesRepoPath, LazyLockFile)
+	}
+
+	after := hashLazyLock(lockPath)
+	if after == "" || (after == before && !options.purgeNvim) {
+		return nil
+	}
+
+	dataPath := getNvimDataPath()
+	restore := []string{"--headless", "+Lazy! restore", "+qa"}
+
+	if dryRun {
+		if options.purgeNvim {
+			reportDryRun(getHomePath(), "rm", "-rf", dataPath)
+		}
+		reportDryRun(getHomePath(), "nvim", restore...)
+		return nil
+	}
+
+	if _, err := exec.LookPath("nvim"); err != nil {
+		return errors.New("nvim is not installed, so its plugins were not restored from " + LazyLockFile)
+	}
+
+	if options.purgeNvim {
+		fmt.Printf("\nRemoving [%s]\n", dataPath)
+		if err := os.RemoveAll(dataPath); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n%s: restoring plugins from [%s]\n", config.name, lockPath)
+
+	cmd := exec.Command("nvim", restore...)
+	cmd.Dir = getHomePath()
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("`nvim --headless \"+Lazy! restore\" +qa` failed: %w", err)
+	}
+
+	return nil
+}
+
+/*
+ * Runs `resetNvim` for every nvim config, reporting failures without stopping, since
+ * the configs themselves were already copied.
+ */
+func resetNvims(configs []Config, before map[string]string, options PullOptions, dryRun bool) {
+	for _, config := range configs {
+		if config.kind != TypeNvim {
+			continue
+		}
+
+		if err := resetNvim(config, before[config.name], options, dryRun); err != nil {
+			fmt.Fprintf(os.Stderr, "Error resetting [%s]: %v\n", config.name, err)
+		}
+	}
+}
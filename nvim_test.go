@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestResetNvim(t *testing.T) {
+	// A fake nvim records that it ran
+	bin := t.TempDir()
+	ran := path.Join(t.TempDir(), "ran")
+	os.WriteFile(path.Join(bin, "nvim"), []byte("#!/bin/sh\necho \"$@\" >> "+ran+"\n"), 0755)
+	t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	os.MkdirAll(path.Join(dataHome, "nvim", "mason"), 0755)
+
+	repoDir := t.TempDir()
+	installDir := t.TempDir()
+	os.WriteFile(path.Join(repoDir, LazyLockFile), []byte(`{"lazy.nvim": {"commit": "a"}}`), 0644)
+	os.WriteFile(path.Join(installDir, LazyLockFile), []byte(`{"lazy.nvim": {"commit": "a"}}`), 0644)
+
+	config := Config{
+		name:                  "nvim",
+		dir:                   true,
+		localInstallPath:      InstallPaths{DefaultInstallKey: installDir},
+		localDotfilesRepoPath: repoDir,
+		kind:                  TypeNvim,
+	}
+
+	// An unchanged lockfile restores nothing
+	before := hashNvimLocks(SyncState{}, []Config{config})
+	if err := resetNvim(config, before["nvim"], PullOptions{}, false); err != nil || pathExists(ran) {
+		t.Errorf("Expected nvim not to run for an unchanged lockfile; err: %v", err)
+	}
+
+	// Happy path - a changed lockfile restores plugins, keeping the data directory
+	os.WriteFile(path.Join(installDir, LazyLockFile), []byte(`{"lazy.nvim": {"commit": "b"}}`), 0644)
+	if err := resetNvim(config, before["nvim"], PullOptions{}, false); err != nil {
+		t.Fatalf("Unexpected error resetting nvim: %v", err)
+	}
+	content, _ := os.ReadFile(ran)
+	if string(content) != "--headless +Lazy! restore +qa\n" {
+		t.Errorf("nvim arguments (%q) not as expected", content)
+	}
+	if !pathExists(path.Join(dataHome, "nvim", "mason")) {
+		t.Errorf("Expected the data directory to be kept")
+	}
+
+	// The data directory is only removed when asked
+	if err := resetNvim(config, hashLazyLock(path.Join(installDir, LazyLockFile)), PullOptions{purgeNvim: true}, false); err != nil {
+		t.Fatalf("Unexpected error purging nvim: %v", err)
+	}
+	if pathExists(path.Join(dataHome, "nvim")) {
+		t.Errorf("Expected the data directory to be purged")
+	}
+
+	// Sad path - nvim is not installed
+	t.Setenv("PATH", t.TempDir())
+	if err := resetNvim(config, "", PullOptions{}, false); err == nil {
+		t.Errorf("Expected an error restoring plugins without nvim")
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Directory of ConfigsSrc holding the package lists, one per profile
+const PackagesDir = "packages"
+
+// Package list used by machines without a profile, or whose profile has no list
+const DefaultPackageList = "default"
+
+/*
+ * PackageLists
+ *
+ * The global packages a machine should have, keyed by the name of their
+ * `PackageManager`, stored as JSON in ConfigsSrc/packages/<profile>.json:
+ *
+ *	{ "npm": ["eslint", "typescript"], "go": ["golang.org/x/tools/gopls@latest"] }
+ *
+ * A pull installs the packages missing from this machine, and a push replaces each
+ * list with what is installed now. Only the managers in the file are synced, so a
+ * manager is opted into by adding it, even with an empty list.
+ */
+type PackageLists map[string][]string
+
+/*
+ * PackageManager
+ *
+ * `list` is the command printing the installed packages, which `parse` reads, and
+ * `install` returns the command installing one package. `binaries` are the programs
+ * those commands run, which must be on $PATH to sync the manager, and `bundled` are the
+ * packages it ships with, which are only captured when the list already has them.
+ * When `versioned` is set, a package may carry an "@<version>" suffix that is ignored
+ * when comparing it with the installed ones.
+ */
+type PackageManager struct {
+	name      string
+	binaries  []string
+	bundled   []string
+	list      []string
+	parse     func(output []byte) ([]string, error)
+	install   func(pkg string) []string
+	versioned bool
+}
+
+// The package managers configpp syncs, in the order they are synced; tests swap them
+// for fakes
+var PackageManagers = []PackageManager{
+	{
+		name:      "npm",
+		binaries:  []string{"npm"},
+		bundled:   []string{"corepack", "npm"},
+		list:      []string{"npm", "ls", "--global", "--depth=0", "--json"},
+		parse:     parseNpmPackages,
+		install:   func(pkg string) []string { return []string{"npm", "install", "--global", pkg} },
+		versioned: true,
+	},
+	{
+		name:     "brew",
+		binaries: []string{"brew"},
+		list:     []string{"brew", "leaves", "--installed-on-request"},
+		parse:    parsePackageLines,
+		install:  func(pkg string) []string { return []string{"brew", "install", pkg} },
+	},
+	{
+		name:     "apt",
+		binaries: []string{"apt-mark", "apt-get"},
+		list:     []string{"apt-mark", "showmanual"},
+		parse:    parsePackageLines,
+		install:  func(pkg string) []string { return []string{"sudo", "apt-get", "install", "-y", pkg} },
+	},
+	{
+		name:     "cargo",
+		binaries: []string{"cargo"},
+		list:     []string{"cargo", "install", "--list"},
+		parse:    parseCargoPackages,
+		install:  func(pkg string) []string { return []string{"cargo", "install", pkg} },
+	},
+	{
+		name:     "go",
+		binaries: []string{"go"},
+		// NOTE: `go install` puts binaries in $GOBIN, else $GOPATH/bin
+		list:  []string{"sh", "-c", `go version -m "$(go env GOBIN)" 2>/dev/null || go version -m "$(go env GOPATH)/bin"`},
+		parse: parseGoPackages,
+		install: func(pkg string) []string {
+			if !strings.Contains(pkg, "@") {
+				pkg += "@latest"
+			}
+			return []string{"go", "install", pkg}
+		},
+		versioned: true,
+	},
+}
+
+/*
+ * Returns the packages installed with the manager, sorted.
+ */
+func (manager PackageManager) installed() ([]string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(manager.list[0], manager.list[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// NOTE: `npm ls` exits non-zero for problems such as invalid peer dependencies, but still lists
+	if err := cmd.Run(); err != nil && stdout.Len() == 0 {
+		return nil, fmt.Errorf("`%s` failed: %w: %s", strings.Join(manager.list, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	packages, err := manager.parse(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("reading the output of `%s`: %w", strings.Join(manager.list, " "), err)
+	}
+	slices.Sort(packages)
+
+	return slices.Compact(packages), nil
+}
+
+/*
+ * Returns whether every program the package manager runs is on $PATH.
+ */
+func (manager PackageManager) isInstalled() bool {
+	for _, binary := range manager.binaries {
+		if _, err := exec.LookPath(binary); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+ * Returns the packages of the list that are not installed.
+ */
+func (manager PackageManager) missing(packages []string, installed []string) []string {
+	var missing []string
+	for _, pkg := range packages {
+		if !slices.Contains(installed, manager.packageName(pkg)) {
+			missing = append(missing, pkg)
+		}
+	}
+
+	return missing
+}
+
+/*
+ * Returns a listed package without its pinned version.
+ */
+func (manager PackageManager) packageName(pkg string) string {
+	// NOTE: the "@" of a scoped npm package (i.e. @types/node) starts its name
+	if i := strings.LastIndex(pkg, "@"); manager.versioned && i > 0 {
+		return pkg[:i]
+	}
+
+	return pkg
+}
+
+/*
+ * Captures the packages installed on this machine into the package list at `listPath`,
+ * for the managers `lists` (the list's current content) has. During a dry run, the
+ * changes are reported instead. Returns whether the list changed.
+ */
+func capturePackages(listPath string, lists PackageLists, dryRun bool) (bool, error) {
+	var errs []error
+	changed := false
+	for _, manager := range PackageManagers {
+		packages, ok := lists[manager.name]
+		if !ok || !manager.isInstalled() {
+			continue
+		}
+
+		installed, err := manager.installed()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", manager.name, err))
+			continue
+		}
+
+		// NOTE: listed packages keep their pinned version when they are still installed
+		captured, added := []string{}, []string{}
+		for _, name := range installed {
+			pinned := slices.IndexFunc(packages, func(pkg string) bool { return manager.packageName(pkg) == name })
+			switch {
+			case pinned == -1 && slices.Contains(manager.bundled, name):
+				continue
+			case pinned == -1:
+				captured = append(captured, name)
+				added = append(added, name)
+			default:
+				captured = append(captured, packages[pinned])
+			}
+		}
+
+		removed := manager.missing(packages, installed)
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		changed = true
+		lists[manager.name] = captured
+		printPackageChanges(manager.name, added, removed, dryRun)
+	}
+
+	if changed && !dryRun {
+		if err := savePackageLists(listPath, lists); err != nil {
+			return false, err
+		}
+	}
+
+	return changed, errors.Join(errs...)
+}
+
+/*
+ * Returns the package list a push captures into, and its content: the list of this
+ * machine's profile, which starts out with the managers of `DefaultPackageList` (and
+ * no packages) when the profile does not have one yet, so a profiled machine never
+ * rewrites the default list. Returns nil lists when there is nothing to capture.
+ */
+func getCapturePackageLists(profile string) (string, PackageLists, error) {
+	defaultPath := path.Join(ConfigsSrc, PackagesDir, DefaultPackageList+".json")
+	if profile == "" {
+		lists, err := loadPackageLists(defaultPath)
+		return defaultPath, lists, err
+	}
+
+	listPath := path.Join(ConfigsSrc, PackagesDir, profile+".json")
+	if pathExists(listPath) {
+		lists, err := loadPackageLists(listPath)
+		return listPath, lists, err
+	}
+
+	defaults, err := loadPackageLists(defaultPath)
+	if err != nil || defaults == nil {
+		return listPath, nil, err
+	}
+
+	lists := PackageLists{}
+	for name := range defaults {
+		lists[name] = []string{}
+	}
+
+	return listPath, lists, nil
+}
+
+/*
+ * Returns the package list a pull installs from: the list of this machine's profile,
+ * falling back to `DefaultPackageList` when the profile does not have one.
+ */
+func getPackageListPath(profile string) string {
+	if profile != "" {
+		if listPath := path.Join(ConfigsSrc, PackagesDir, profile+".json"); pathExists(listPath) {
+			return listPath
+		}
+	}
+
+	return path.Join(ConfigsSrc, PackagesDir, DefaultPackageList+".json")
+}
+
+/*
+ * Installs the packages of the list that are missing from this machine, reporting
+ * managers that are not installed. During a dry run, the installs are reported instead.
+ * A failed install does not stop the others, and every failure is returned.
+ */
+func installPackages(listPath string, dryRun bool) error {
+	lists, err := loadPackageLists(listPath)
+	if err != nil || lists == nil {
+		return err
+	}
+
+	var errs []error
+	for _, manager := range PackageManagers {
+		packages := lists[manager.name]
+		if len(packages) == 0 {
+			continue
+		}
+
+		if !manager.isInstalled() {
+			fmt.Printf("\nSkipping %s packages; %s is not installed\n", manager.name, manager.name)
+			continue
+		}
+
+		installed, err := manager.installed()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", manager.name, err))
+			continue
+		}
+
+		for _, pkg := range manager.missing(packages, installed) {
+			command := manager.install(pkg)
+			if dryRun {
+				reportDryRun(getHomePath(), command[0], command[1:]...)
+				continue
+			}
+
+			fmt.Printf("\n%s: installing %s\n", manager.name, pkg)
+
+			cmd := exec.Command(command[0], command[1:]...)
+			cmd.Dir = getHomePath()
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+
+			if err := cmd.Run(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: `%s` failed: %w", manager.name, strings.Join(command, " "), err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+ * Returns the package lists at the provided path, or nil when there are none.
+ */
+func loadPackageLists(listPath string) (PackageLists, error) {
+	content, err := os.ReadFile(listPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lists PackageLists
+	if err := json.Unmarshal(content, &lists); err != nil {
+		return nil, fmt.Errorf("parsing [%s]: %w", listPath, err)
+	}
+
+	for name := range lists {
+		if !slices.ContainsFunc(PackageManagers, func(manager PackageManager) bool { return manager.name == name }) {
+			return nil, fmt.Errorf("[%s]: unknown package manager %q", listPath, name)
+		}
+	}
+
+	return lists, nil
+}
+
+/*
+ * Reads the dependencies of `npm ls --json`.
+ */
+func parseNpmPackages(output []byte) ([]string, error) {
+	var tree struct {
+		Dependencies map[string]json.RawMessage `json:"dependencies"`
+	}
+	if err := json.Unmarshal(output, &tree); err != nil {
+		return nil, err
+	}
+
+	return slices.Collect(maps.Keys(tree.Dependencies)), nil
+}
+
+/*
+ * Reads the crates of `cargo install --list`, whose binaries are indented under them:
+ *
+ *	ripgrep v14.1.0:
+ *	    rg
+ */
+func parseCargoPackages(output []byte) ([]string, error) {
+	var packages []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(line, " ") {
+			packages = append(packages, fields[0])
+		}
+	}
+
+	return packages, nil
+}
+
+/*
+ * Reads the packages of `go version -m <dir>`, which lists each binary's build info:
+ *
+ *	/home/me/go/bin/gopls: go1.23.2
+ *		path	golang.org/x/tools/gopls
+ */
+func parseGoPackages(output []byte) ([]string, error) {
+	var packages []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "path" {
+			packages = append(packages, fields[1])
+		}
+	}
+
+	return packages, nil
+}
+
+/*
+ * Reads one package per line, ignoring blank lines.
+ */
+func parsePackageLines(output []byte) ([]string, error) {
+	return strings.Fields(string(output)), nil
+}
+
+func printPackageChanges(manager string, added []string, removed []string, dryRun bool) {
+	prefix := ""
+	if dryRun {
+		prefix = "[dry-run] "
+	}
+
+	fmt.Printf("\n%s%s packages\n", prefix, manager)
+	for _, pkg := range added {
+		fmt.Printf("  + %s\n", pkg)
+	}
+	for _, pkg := range removed {
+		fmt.Printf("  - %s\n", pkg)
+	}
+}
+
+func printPackageSync(listPath string) {
+	fmt.Printf("-----------------------------------\n")
+	fmt.Printf("\nSyncing packages of [%s]\n", listPath)
+}
+
+func savePackageLists(listPath string, lists PackageLists) error {
+	content, err := json.MarshalIndent(lists, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(listPath, append(content, '\n'), 0644)
+}
+
+/*
+ * Syncs the package list of this machine's profile: downstream installs the missing
+ * packages (see `getPackageListPath`), and upstream captures the installed ones (see
+ * `getCapturePackageLists`). Runs narrowed to some configs
+ * leave packages alone.
+ */
+func syncPackages(names []string, upstream bool) error {
+	if len(names) > 0 || *FlagOnly != "" {
+		return nil
+	}
+
+	profile, _, err := loadProfile(*FlagManifest, *FlagProfile)
+	if err != nil {
+		return err
+	}
+
+	if upstream {
+		listPath, lists, err := getCapturePackageLists(profile)
+		if err != nil || lists == nil {
+			return err
+		}
+
+		printPackageSync(listPath)
+		_, err = capturePackages(listPath, lists, *FlagDryRun)
+		return err
+	}
+
+	listPath := getPackageListPath(profile)
+	if !pathExists(listPath) {
+		return nil
+	}
+
+	printPackageSync(listPath)
+	return installPackages(listPath, *FlagDryRun)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSyncPackages(t *testing.T) {
+	configsSrc := configsSandbox(t, "")
+
+	// A fake npm keeps its global packages in a file, one per line
+	bin := t.TempDir()
+	installed := path.Join(t.TempDir(), "installed")
+	os.WriteFile(installed, []byte("typescript\n"), 0644)
+	os.WriteFile(path.Join(bin, "npm"), []byte(`#!/bin/sh
+case "$1" in
+ls) printf '{"dependencies": {'; sep=""; for pkg in $(cat `+installed+`); do printf '%s"%s": {}' "$sep" "$pkg"; sep=", "; done; printf '}}' ;;
+install) echo "${3%@*}" >> `+installed+` ;;
+esac
+`), 0755)
+	t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+
+	listPath := path.Join(configsSrc, PackagesDir, DefaultPackageList+".json")
+	os.MkdirAll(path.Dir(listPath), 0755)
+	os.WriteFile(listPath, []byte(`{"npm": ["eslint@8", "typescript"]}`), 0644)
+
+	// Dry runs install nothing
+	if err := installPackages(listPath, true); err != nil {
+		t.Fatalf("Unexpected error previewing installs: %v", err)
+	}
+	if content, _ := os.ReadFile(installed); string(content) != "typescript\n" {
+		t.Errorf("Expected a dry run not to install anything; installed: %q", content)
+	}
+
+	// Happy path - only the missing package is installed
+	if err := installPackages(listPath, false); err != nil {
+		t.Fatalf("Unexpected error installing packages: %v", err)
+	}
+	if content, _ := os.ReadFile(installed); string(content) != "typescript\neslint\n" {
+		t.Errorf("Installed packages (%q) not as expected", content)
+	}
+
+	// Capturing keeps pinned versions and picks up packages installed by hand, but not
+	// the packages npm ships with
+	os.WriteFile(installed, []byte("corepack\neslint\nnpm\nprettier\n"), 0644)
+	lists, _ := loadPackageLists(listPath)
+	changed, err := capturePackages(listPath, lists, false)
+	if err != nil || !changed {
+		t.Fatalf("Expected the list to change; err: %v", err)
+	}
+	lists, _ = loadPackageLists(listPath)
+	if expect := []string{"eslint@8", "prettier"}; !slices.Equal(lists["npm"], expect) {
+		t.Errorf("Captured packages (%v) not as expected (%v)", lists["npm"], expect)
+	}
+
+	// A profiled push captures into the profile's list, started from the default's
+	// managers, and leaves the default list alone
+	os.WriteFile(path.Join(configsSrc, ManifestFile), []byte(`{"configs": [{"name": "nvim", "repo": "nvim", "install": "/tmp/nvim"}], "profiles": {"work": {}}}`), 0644)
+	*FlagProfile = "work"
+	defer func() { *FlagProfile = "" }()
+
+	defaults, _ := os.ReadFile(listPath)
+	if err := syncPackages(nil, true); err != nil {
+		t.Fatalf("Unexpected error capturing packages: %v", err)
+	}
+	if content, _ := os.ReadFile(listPath); string(content) != string(defaults) {
+		t.Errorf("Expected the default list to be unchanged; default.json: %s", content)
+	}
+	profileLists, _ := loadPackageLists(path.Join(configsSrc, PackagesDir, "work.json"))
+	if expect := []string{"eslint", "prettier"}; !slices.Equal(profileLists["npm"], expect) {
+		t.Errorf("Captured profile packages (%v) not as expected (%v)", profileLists["npm"], expect)
+	}
+
+	// Managers are only synced when every program they run is on $PATH
+	apt, previousPath := PackageManagers[2], os.Getenv("PATH")
+	os.WriteFile(path.Join(bin, "apt-mark"), []byte("#!/bin/sh\n"), 0755)
+	t.Setenv("PATH", bin)
+	if apt.isInstalled() {
+		t.Errorf("Expected apt not to be installed without apt-get")
+	}
+	os.WriteFile(path.Join(bin, "apt-get"), []byte("#!/bin/sh\n"), 0755)
+	if !apt.isInstalled() {
+		t.Errorf("Expected apt to be installed with apt-mark and apt-get")
+	}
+	t.Setenv("PATH", previousPath)
+
+	// Sad path - unknown package managers
+	os.WriteFile(listPath, []byte(`{"pip": ["black"]}`), 0644)
+	if err := installPackages(listPath, false); err == nil || !strings.Contains(err.Error(), "unknown package manager") {
+		t.Errorf("Expected an error for an unknown package manager; err: %v", err)
+	}
+}
+
+func TestParsePackages(t *testing.T) {
+	cargo, _ := parseCargoPackages([]byte("ripgrep v14.1.0:\n    rg\nzellij v0.40.1:\n    zellij\n"))
+	if expect := []string{"ripgrep", "zellij"}; !slices.Equal(cargo, expect) {
+		t.Errorf("Cargo packages (%v) not as expected (%v)", cargo, expect)
+	}
+
+	goPackages, _ := parseGoPackages([]byte("/home/me/go/bin/gopls: go1.23.2\n\tpath\tgolang.org/x/tools/gopls\n\tmod\tgolang.org/x/tools/gopls\tv0.16.2\n"))
+	if expect := []string{"golang.org/x/tools/gopls"}; !slices.Equal(goPackages, expect) {
+		t.Errorf("Go packages (%v) not as expected (%v)", goPackages, expect)
+	}
+
+	npm := PackageManagers[0]
+	if name := npm.packageName("@types/node@20"); name != "@types/node" {
+		t.Errorf("Package name (%s) not as expected (@types/node)", name)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+/*
+ * Profile
+ *
+ * A named kind of machine, such as "work", "personal", or "server", declared in the
+ * manifest's `profiles`. A machine uses the profile picked with `-profile`, else the one
+ * whose `hosts` match its hostname, else none, which syncs every config.
+ *
+ * `hosts` are glob patterns (i.e. "srv-*") matched against the hostname.
+ * `configs` limits the profile to those configs (all of them when empty), and `skip`
+ * leaves configs out, such as Ghostty and Alacritty on a headless server.
+ * `install` replaces a config's install paths, keyed by config name, and `variables`
+ * override the manifest's variables of the same name.
+ * `remote` and `branch` override the manifest's for the profile's machines (see `getConfigsGit`).
+ */
+type Profile struct {
+	Hosts     []string                `json:"hosts,omitempty"`
+	Configs   []string                `json:"configs,omitempty"`
+	Skip      []string                `json:"skip,omitempty"`
+	Install   map[string]InstallPaths `json:"install,omitempty"`
+	Variables map[string]string       `json:"variables,omitempty"`
+	Remote    string                  `json:"remote,omitempty"`
+	Branch    string                  `json:"branch,omitempty"`
+}
+
+/*
+ * Narrows the configs to the ones the profile syncs and applies its install paths and
+ * variables. Returns the names of the configs it left out.
+ */
+func (profile Profile) apply(configs []Config) ([]Config, []string) {
+	var applied []Config
+	var skipped []string
+
+	for _, config := range configs {
+		if len(profile.Configs) > 0 && !slices.Contains(profile.Configs, config.name) || slices.Contains(profile.Skip, config.name) {
+			skipped = append(skipped, config.name)
+			continue
+		}
+
+		if installPaths, ok := profile.Install[config.name]; ok {
+			config.localInstallPath = InstallPaths{}
+			for key, installPath := range installPaths {
+				config.localInstallPath[key] = replaceTildeInPath(installPath)
+			}
+		}
+
+		if len(profile.Variables) > 0 {
+			variables := maps.Clone(config.variables)
+			if variables == nil {
+				variables = map[string]string{}
+			}
+			maps.Copy(variables, profile.Variables)
+			config.variables = variables
+		}
+
+		applied = append(applied, config)
+	}
+
+	return applied, skipped
+}
+
+/*
+ * Returns the profile of this machine from the manifest at the provided path: `name`
+ * when it is not empty, else the one matching the hostname (see `matchProfile`).
+ *
+ * An empty name and profile are returned when no profile applies, including when
+ * there is no manifest.
+ */
+func loadProfile(manifestPath string, name string) (string, Profile, error) {
+	manifest, err := readManifest(manifestPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", Profile{}, err
+	}
+
+	if name == "" {
+		name, err = matchProfile(manifest.Profiles, getHostname())
+		if err != nil {
+			return "", Profile{}, err
+		}
+		if name == "" {
+			return "", Profile{}, nil
+		}
+	}
+
+	profile, ok := manifest.Profiles[name]
+	if !ok {
+		names := slices.Sorted(maps.Keys(manifest.Profiles))
+		if len(names) == 0 {
+			return "", Profile{}, fmt.Errorf("unknown profile %q; [%s] does not declare any profiles", name, manifestPath)
+		}
+		if suggestion := suggestConfigName(name, names); suggestion != "" {
+			return "", Profile{}, fmt.Errorf("unknown profile %q; did you mean %q?", name, suggestion)
+		}
+		return "", Profile{}, fmt.Errorf("unknown profile %q; available profiles: %s", name, strings.Join(names, ", "))
+	}
+
+	return name, profile, nil
+}
+
+/*
+ * Returns the name of the profile whose `hosts` match the hostname, or "" when none do.
+ *
+ * Returns an error when several profiles match, since picking one would depend on
+ * their order.
+ */
+func matchProfile(profiles map[string]Profile, hostname string) (string, error) {
+	var matches []string
+	for _, name := range slices.Sorted(maps.Keys(profiles)) {
+		for _, pattern := range profiles[name].Hosts {
+			if matched, _ := path.Match(pattern, hostname); matched {
+				matches = append(matches, name)
+				break
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", nil
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("hostname %q matches the profiles %s; pick one with -profile", hostname, strings.Join(matches, ", "))
+	}
+}
+
+/*
+ * Narrows the configs to this machine's profile. Configs named on the command line that
+ * the profile leaves out are reported, since skipping them silently would look like a
+ * failed sync.
+ */
+func selectProfileConfigs(configs []Config, only []string) ([]Config, error) {
+	name, profile, err := loadProfile(*FlagManifest, *FlagProfile)
+	if err != nil {
+		return nil, fmt.Errorf("loading profile: %w", err)
+	}
+	if name == "" {
+		return configs, nil
+	}
+
+	configs, skipped := profile.apply(configs)
+	for _, configName := range skipped {
+		if slices.Contains(only, configName) {
+			fmt.Fprintf(os.Stderr, "Skipping [%s]; the %q profile does not sync it\n", configName, name)
+		}
+	}
+
+	return configs, nil
+}
+
+/*
+ * Returns every problem with the manifest's profiles.
+ */
+func validateProfiles(manifest Manifest) []error {
+	var errs []error
+
+	names := map[string]bool{}
+	for _, entry := range manifest.Configs {
+		names[entry.Name] = true
+	}
+
+	for _, profileName := range slices.Sorted(maps.Keys(manifest.Profiles)) {
+		profile := manifest.Profiles[profileName]
+		label := fmt.Sprintf("profile %q", profileName)
+
+		if profileName == "" || strings.ContainsAny(profileName, " ,/") {
+			errs = append(errs, fmt.Errorf("%s: names cannot be empty or contain spaces, commas, or slashes", label))
+		}
+
+		errs = append(errs, validateRemoteAndBranch(label, profile.Remote, profile.Branch)...)
+
+		for _, pattern := range profile.Hosts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("%s: host pattern %q is invalid", label, pattern))
+			}
+		}
+
+		configNames := slices.Concat(profile.Configs, profile.Skip, slices.Sorted(maps.Keys(profile.Install)))
+		for _, configName := range configNames {
+			if !names[configName] {
+				errs = append(errs, fmt.Errorf("%s: unknown config %q", label, configName))
+			}
+		}
+
+		for _, configName := range slices.Sorted(maps.Keys(profile.Install)) {
+			errs = append(errs, validateInstallPaths(fmt.Sprintf("%s, config %q", label, configName), profile.Install[configName])...)
+		}
+	}
+
+	return errs
+}
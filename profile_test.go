@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigsWithProfile(t *testing.T) {
+	configsSrc := configsSandbox(t, "")
+	defer func() { *FlagProfile = "" }()
+
+	manifest := `{
+		"variables": {"email": "me@home.com", "font": "14"},
+		"profiles": {
+			"server": {"hosts": ["srv-*", "` + getHostname() + `"], "skip": ["ghostty"], "install": {"nvim": "/opt/nvim"}},
+			"work": {"configs": ["ghostty"], "variables": {"email": "me@work.com"}}
+		},
+		"configs": [
+			{"name": "nvim", "dir": true, "repo": "nvim", "install": "~/.config/nvim"},
+			{"name": "ghostty", "dir": true, "repo": "ghostty", "install": "~/.config/ghostty"}
+		]
+	}`
+	os.WriteFile(path.Join(configsSrc, ManifestFile), []byte(manifest), 0644)
+
+	// The hostname picks the server profile
+	configs, err := loadConfigs(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error loading configs: %v", err)
+	}
+	if len(configs) != 1 || configs[0].name != "nvim" || configs[0].localInstallPath[DefaultInstallKey] != "/opt/nvim" {
+		t.Errorf("Expected only nvim, installed in /opt/nvim; got %+v", configs)
+	}
+
+	// -profile beats the hostname
+	*FlagProfile = "work"
+	configs, err = loadConfigs(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error loading configs: %v", err)
+	}
+	if len(configs) != 1 || configs[0].name != "ghostty" || configs[0].variables["email"] != "me@work.com" || configs[0].variables["font"] != "14" {
+		t.Errorf("Expected only ghostty, with the work email and the shared font; got %+v", configs)
+	}
+
+	// Sad path - unknown profile
+	*FlagProfile = "sever"
+	if _, err := loadConfigs(nil); err == nil || !strings.Contains(err.Error(), `did you mean "server"?`) {
+		t.Errorf("Expected a suggestion for an unknown profile; err: %v", err)
+	}
+}
+
+func TestMatchProfile(t *testing.T) {
+	profiles := map[string]Profile{
+		"laptop": {Hosts: []string{"mbp-*"}},
+		"server": {Hosts: []string{"srv-*", "build"}},
+		"any":    {Hosts: []string{"build*"}},
+	}
+
+	tests := []InputOutput{
+		{input: "mbp-home", output: "laptop"},
+		{input: "srv-01", output: "server"},
+		{input: "desktop", output: ""},
+	}
+
+	for _, test := range tests {
+		name, err := matchProfile(profiles, test.input)
+		if err != nil || name != test.output {
+			t.Errorf("Profile of %q (%q) not as expected (%q); err: %v", test.input, name, test.output, err)
+		}
+	}
+
+	// Sad path - several profiles match
+	if _, err := matchProfile(profiles, "build"); err == nil {
+		t.Errorf("Expected an error when several profiles match")
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	tests := []InputOutput{
+		{input: `{"profiles": {"server": {"skip": ["ghosty"]}}, "configs": [{"name": "ghostty", "repo": "ghostty", "install": "~/.config/ghostty"}]}`, output: `unknown config "ghosty"`},
+		{input: `{"profiles": {"server": {"hosts": ["srv-["]}}, "configs": [{"name": "ghostty", "repo": "ghostty", "install": "~/.config/ghostty"}]}`, output: "is invalid"},
+		{input: `{"profiles": {"server": {"install": {"ghostty": "ghostty"}}}, "configs": [{"name": "ghostty", "repo": "ghostty", "install": "~/.config/ghostty"}]}`, output: "must be absolute"},
+		{input: `{"profiles": {"server": {"branch": "-f"}}, "configs": [{"name": "ghostty", "repo": "ghostty", "install": "~/.config/ghostty"}]}`, output: `profile "server": "-f" is not a valid "branch"`},
+	}
+
+	for _, test := range tests {
+		_, err := parseManifest(strings.NewReader(test.input))
+
+		if err == nil || !strings.Contains(err.Error(), test.output) {
+			t.Errorf("Expected error containing %q; manifest: %s; err: %v", test.output, test.input, err)
+		}
+	}
+}
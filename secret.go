@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// Repo files ending with these suffixes are decrypted into the install path without them
+const (
+	AgeSuffix        = ".age"
+	PassphraseSuffix = ".enc"
+)
+
+// First line of a file encrypted with a passphrase, naming the format of the rest
+const passphraseHeader = "configpp-secret v1"
+
+// PBKDF2-HMAC-SHA256 iterations deriving the key of a passphrase encrypted file
+const passphraseIterations = 600000
+
+// Keys derived during this run, keyed by passphrase and salt, since deriving one takes
+// `passphraseIterations` rounds and every file of a config shares the passphrase
+var passphraseKeys = map[string][]byte{}
+
+/*
+ * SecretCipher
+ *
+ * Encrypts and decrypts the content of secret files. `getSecretCipher` picks one by the
+ * file's suffix.
+ */
+type SecretCipher interface {
+	encrypt(plaintext []byte) ([]byte, error)
+	decrypt(ciphertext []byte) ([]byte, error)
+}
+
+/*
+ * AgeCipher
+ *
+ * Encrypts with the age CLI to the recipient of `identity`, plus the recipients listed
+ * in `recipients` when it exists, so every machine's key can decrypt the file.
+ */
+type AgeCipher struct {
+	identity   string
+	recipients string
+}
+
+/*
+ * PassphraseCipher
+ *
+ * Encrypts with AES-256-GCM, using a key derived from the passphrase and a random salt
+ * stored with each file.
+ */
+type PassphraseCipher struct {
+	passphrase string
+}
+
+func (age AgeCipher) decrypt(ciphertext []byte) ([]byte, error) {
+	return age.run(ciphertext, "--decrypt", "-i", age.identity)
+}
+
+func (age AgeCipher) encrypt(plaintext []byte) ([]byte, error) {
+	args := []string{"--encrypt", "--armor", "-i", age.identity}
+	if pathExists(age.recipients) {
+		args = append(args, "-R", age.recipients)
+	}
+
+	return age.run(plaintext, args...)
+}
+
+/*
+ * Runs age with the input on stdin and returns what it printed to stdout.
+ */
+func (age AgeCipher) run(input []byte, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("age", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("`age %s` failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func (passphrase PassphraseCipher) decrypt(ciphertext []byte) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(string(ciphertext), passphraseHeader+"\n")
+	if !ok {
+		return nil, fmt.Errorf("not a configpp secret; expected it to start with %q", passphraseHeader)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 16 {
+		return nil, errors.New("secret is truncated")
+	}
+
+	gcm, err := passphrase.gcm(data[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[16:]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("secret is truncated")
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted secret")
+	}
+
+	return plaintext, nil
+}
+
+func (passphrase PassphraseCipher) encrypt(plaintext []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := passphrase.gcm(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	data := append(salt, gcm.Seal(nonce, nonce, plaintext, nil)...)
+
+	return []byte(passphraseHeader + "\n" + base64.StdEncoding.EncodeToString(data) + "\n"), nil
+}
+
+/*
+ * Returns the AES-256-GCM cipher of the passphrase and salt, deriving its key only the
+ * first time the pair is seen (see `passphraseKeys`).
+ */
+func (passphrase PassphraseCipher) gcm(salt []byte) (cipher.AEAD, error) {
+	cacheKey := passphrase.passphrase + "\x00" + string(salt)
+
+	key, ok := passphraseKeys[cacheKey]
+	if !ok {
+		key = pbkdf2SHA256([]byte(passphrase.passphrase), salt, passphraseIterations, 32)
+		passphraseKeys[cacheKey] = key
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+/*
+ * Returns the secret in the repo that installs `repoPath`: the path itself when it is a
+ * secret, else the path with a secret suffix when that exists.
+ */
+func findSecret(repoPath string) (string, bool) {
+	if isSecret(repoPath) {
+		return repoPath, pathExists(repoPath)
+	}
+
+	for _, suffix := range []string{AgeSuffix, PassphraseSuffix} {
+		if pathExists(repoPath + suffix) {
+			return repoPath + suffix, true
+		}
+	}
+
+	return "", false
+}
+
+/*
+ * Returns the age identity file, $CONFIGPP_AGE_IDENTITY, defaulting to
+ * ~/.config/configpp/age-identity.txt.
+ */
+func getAgeIdentity() string {
+	if identity := os.Getenv("CONFIGPP_AGE_IDENTITY"); identity != "" {
+		return replaceTildeInPath(identity)
+	}
+
+	return path.Join(getHomePath(), ".config", "configpp", "age-identity.txt")
+}
+
+/*
+ * Returns the cipher of a secret file: age for `AgeSuffix`, and the passphrase in
+ * $CONFIGPP_PASSPHRASE for `PassphraseSuffix`.
+ */
+func getSecretCipher(filePath string) (SecretCipher, error) {
+	switch {
+	case strings.HasSuffix(filePath, AgeSuffix):
+		return AgeCipher{identity: getAgeIdentity(), recipients: path.Join(ConfigsSrc, ".age-recipients")}, nil
+	case strings.HasSuffix(filePath, PassphraseSuffix):
+		passphrase := os.Getenv("CONFIGPP_PASSPHRASE")
+		if passphrase == "" {
+			return nil, fmt.Errorf("[%s] is encrypted with a passphrase; set CONFIGPP_PASSPHRASE", filePath)
+		}
+		return PassphraseCipher{passphrase: passphrase}, nil
+	default:
+		return nil, fmt.Errorf("[%s] is not a secret; secrets end with %s or %s", filePath, AgeSuffix, PassphraseSuffix)
+	}
+}
+
+func isSecret(filePath string) bool {
+	return strings.HasSuffix(filePath, AgeSuffix) || strings.HasSuffix(filePath, PassphraseSuffix)
+}
+
+/*
+ * Returns the path a secret installs to, without its secret suffix.
+ */
+func trimSecretSuffix(filePath string) string {
+	for _, suffix := range []string{AgeSuffix, PassphraseSuffix} {
+		if trimmed, ok := strings.CutSuffix(filePath, suffix); ok {
+			return trimmed
+		}
+	}
+
+	return filePath
+}
+
+/*
+ * Returns the PBKDF2 key of a password (RFC 8018) with HMAC-SHA256 as the PRF.
+ */
+func pbkdf2SHA256(password []byte, salt []byte, iterations int, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+
+	var key []byte
+	for block := uint32(1); len(key) < keyLength; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+
+		t := bytes.Clone(u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLength]
+}
+
+/*
+ * Returns the plaintext of a secret file.
+ */
+func readSecret(filePath string) ([]byte, error) {
+	secretCipher, err := getSecretCipher(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := secretCipher.decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting [%s]: %w", filePath, err)
+	}
+
+	return plaintext, nil
+}
+
+/*
+ * `configpp secrets edit <file>` decrypts a secret in ConfigsSrc into a private
+ * temporary directory outside the repo, opens it in $VISUAL or $EDITOR (defaulting to
+ * vi), and encrypts it back into the repo when it changed. A missing secret starts empty.
+ *
+ * The plaintext is removed when the editor exits and never written into the repo.
+ */
+func runSecrets(args []string) error {
+	if len(args) != 2 || args[0] != "edit" {
+		return errors.New("expected `configpp secrets edit <file>`, i.e. `configpp secrets edit npm/.npmrc.enc`")
+	}
+
+	secretPath := resolveRepoPath(args[1])
+	secretCipher, err := getSecretCipher(secretPath)
+	if err != nil {
+		return err
+	}
+
+	var plaintext []byte
+	if pathExists(secretPath) {
+		if plaintext, err = readSecret(secretPath); err != nil {
+			return err
+		}
+	}
+
+	// NOTE: MkdirTemp creates the directory readable only by its owner
+	dir, err := os.MkdirTemp("", "configpp-secret")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	plainPath := path.Join(dir, strings.TrimSuffix(path.Base(secretPath), path.Ext(secretPath)))
+	if err := os.WriteFile(plainPath, plaintext, 0600); err != nil {
+		return err
+	}
+
+	editor := getEditor()
+	cmd := exec.Command(editor[0], append(editor[1:], plainPath)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor `%s` failed: %w", strings.Join(editor, " "), err)
+	}
+
+	edited, err := os.ReadFile(plainPath)
+	if err != nil {
+		return err
+	}
+
+	if pathExists(secretPath) && bytes.Equal(edited, plaintext) {
+		fmt.Printf("\n[%s] is unchanged\n", secretPath)
+		return nil
+	}
+
+	ciphertext, err := secretCipher.encrypt(edited)
+	if err != nil {
+		return fmt.Errorf("encrypting [%s]: %w", secretPath, err)
+	}
+
+	if err := os.MkdirAll(path.Dir(secretPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(secretPath, ciphertext, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nEncrypted [%s]; commit it in [%s] to share it with your other machines\n", secretPath, ConfigsSrc)
+
+	return nil
+}
+
+/*
+ * Returns the editor command, $VISUAL or $EDITOR, defaulting to vi.
+ */
+func getEditor() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(name)); len(editor) > 0 {
+			return editor
+		}
+	}
+
+	return []string{"vi"}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestPbkdf2SHA256(t *testing.T) {
+	// RFC 7914, section 11
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	expect := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+
+	if hex.EncodeToString(key) != expect {
+		t.Errorf("Derived key (%x) not as expected (%s)", key, expect)
+	}
+}
+
+func TestPassphraseCipher(t *testing.T) {
+	passphraseKeys = map[string][]byte{}
+
+	ciphertext, err := PassphraseCipher{passphrase: "hunter2"}.encrypt([]byte("//registry.npmjs.org/:_authToken=abc\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error encrypting: %v", err)
+	}
+	if strings.Contains(string(ciphertext), "_authToken") {
+		t.Errorf("Expected the plaintext not to appear in the ciphertext")
+	}
+
+	plaintext, err := PassphraseCipher{passphrase: "hunter2"}.decrypt(ciphertext)
+	if err != nil || string(plaintext) != "//registry.npmjs.org/:_authToken=abc\n" {
+		t.Errorf("Decrypted plaintext (%q) not as expected; err: %v", plaintext, err)
+	}
+
+	// The key derived while encrypting is reused to decrypt
+	if len(passphraseKeys) != 1 {
+		t.Errorf("Expected one derived key; keys: %d", len(passphraseKeys))
+	}
+
+	// Sad path - wrong passphrase
+	if _, err := (PassphraseCipher{passphrase: "hunter3"}).decrypt(ciphertext); err == nil {
+		t.Errorf("Expected an error decrypting with the wrong passphrase")
+	}
+}
+
+func TestSyncSecrets(t *testing.T) {
+	configsSrc := configsSandbox(t, "")
+	t.Setenv("CONFIGPP_PASSPHRASE", "hunter2")
+
+	installDir := t.TempDir()
+	config := Config{
+		name:                  "npm",
+		dir:                   true,
+		localInstallPath:      InstallPaths{DefaultInstallKey: installDir},
+		localDotfilesRepoPath: path.Join(configsSrc, "npm"),
+	}
+
+	// `secrets edit` encrypts what the editor wrote, starting from an empty file
+	editor := path.Join(t.TempDir(), "editor")
+	os.WriteFile(editor, []byte("#!/bin/sh\necho token=abc > \"$1\"\n"), 0755)
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", editor)
+	if err := runSecrets([]string{"edit", "npm/.npmrc.enc"}); err != nil {
+		t.Fatalf("Unexpected error editing a secret: %v", err)
+	}
+
+	secret, _ := os.ReadFile(path.Join(configsSrc, "npm", ".npmrc.enc"))
+	if !strings.HasPrefix(string(secret), passphraseHeader) || strings.Contains(string(secret), "token") {
+		t.Errorf("Expected an encrypted secret; got %q", secret)
+	}
+
+	// Downstream decrypts without the suffix, readable only by its owner
+	if _, err := cpConfig(config, false, false); err != nil {
+		t.Fatalf("Unexpected error copying secrets: %v", err)
+	}
+
+	info, err := os.Stat(path.Join(installDir, ".npmrc"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected .npmrc to be installed with 0600; info: %v; err: %v", info, err)
+	}
+
+	// Unchanged plaintext is not re-encrypted
+	summary, err := cpConfig(config, true, false)
+	if err != nil || !summary.isEmpty() {
+		t.Errorf("Expected no changes copying an unchanged secret upstream; summary: %+v; err: %v", summary, err)
+	}
+
+	// Changed plaintext is re-encrypted into the secret
+	os.WriteFile(path.Join(installDir, ".npmrc"), []byte("token=def\n"), 0600)
+
+	summary, err = cpConfig(config, true, false)
+	if err != nil || strings.Join(summary.Modified, ",") != ".npmrc" {
+		t.Errorf("Expected .npmrc to be re-encrypted; summary: %+v; err: %v", summary, err)
+	}
+	if pathExists(path.Join(configsSrc, "npm", ".npmrc")) {
+		t.Errorf("Expected no plaintext in the repo")
+	}
+
+	plaintext, err := readSecret(path.Join(configsSrc, "npm", ".npmrc.enc"))
+	if err != nil || string(plaintext) != "token=def\n" {
+		t.Errorf("Re-encrypted secret (%q) not as expected; err: %v", plaintext, err)
+	}
+
+	// Sad path - no passphrase
+	t.Setenv("CONFIGPP_PASSPHRASE", "")
+	if _, err := cpConfig(config, false, true); err == nil {
+		t.Errorf("Expected an error decrypting without a passphrase")
+	}
+}
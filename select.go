@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+/*
+ * Restricts configs to the names in `only` (all configs when empty) minus the names
+ * in `skip`, keeping the manifest's order.
+ *
+ * Every name must belong to a registered config; unknown names are reported together,
+ * with a suggestion when one is close enough to be a typo.
+ */
+func filterConfigs(configs []Config, only []string, skip []string) ([]Config, error) {
+	names := make([]string, len(configs))
+	for i, config := range configs {
+		names[i] = config.name
+	}
+
+	var errs []error
+	for _, name := range append(slices.Clone(only), skip...) {
+		if !slices.Contains(names, name) {
+			errs = append(errs, unknownConfigError(name, names))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	var filtered []Config
+	for _, config := range configs {
+		if len(only) > 0 && !slices.Contains(only, config.name) {
+			continue
+		}
+		if slices.Contains(skip, config.name) {
+			continue
+		}
+
+		filtered = append(filtered, config)
+	}
+
+	return filtered, nil
+}
+
+/*
+ * Returns the closest registered name to `name`, or "" when nothing is close enough
+ * to be a typo.
+ */
+func suggestConfigName(name string, names []string) string {
+	// Allow roughly one typo per three characters, but always at least two
+	maxDistance := max(2, len(name)/3)
+
+	suggestion := ""
+	for _, candidate := range names {
+		distance := levenshtein(name, candidate)
+		if distance <= maxDistance {
+			maxDistance = distance
+			suggestion = candidate
+		}
+	}
+
+	return suggestion
+}
+
+/*
+ * Splits a comma separated flag value, such as "nvim, ghostty", into names.
+ */
+func splitConfigNames(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func unknownConfigError(name string, names []string) error {
+	if suggestion := suggestConfigName(name, names); suggestion != "" {
+		return fmt.Errorf("unknown config %q; did you mean %q?", name, suggestion)
+	}
+
+	return fmt.Errorf("unknown config %q; available configs: %s", name, strings.Join(names, ", "))
+}
+
+/*
+ * Returns the number of single character edits needed to turn `a` into `b`.
+ */
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterConfigs(t *testing.T) {
+	type FilterTest struct {
+		only   []string
+		skip   []string
+		expect []string
+	}
+
+	tests := []FilterTest{
+		{expect: []string{"alacritty", "bashaliases", "bashrc", "eslint", "fontpatcher", "ghostty", "nvim", "stylelint", "vim", "zellij"}},
+		{only: []string{"nvim", "ghostty"}, expect: []string{"ghostty", "nvim"}},
+		{only: []string{"nvim", "ghostty"}, skip: []string{"ghostty"}, expect: []string{"nvim"}},
+		{skip: []string{"alacritty", "bashaliases", "bashrc", "eslint", "fontpatcher", "ghostty", "nvim", "stylelint"}, expect: []string{"vim", "zellij"}},
+	}
+
+	for _, test := range tests {
+		filtered, err := filterConfigs(Configs, test.only, test.skip)
+		if err != nil {
+			t.Errorf("Unexpected error filtering configs; only: %v; skip: %v; err: %v", test.only, test.skip, err)
+		}
+
+		var names []string
+		for _, config := range filtered {
+			names = append(names, config.name)
+		}
+
+		if strings.Join(names, ",") != strings.Join(test.expect, ",") {
+			t.Errorf("Filtered configs (%v) not as expected (%v)", names, test.expect)
+		}
+	}
+
+	// Sad path - unknown names
+	sadTests := []InputOutput{
+		{input: "nvm", output: `did you mean "nvim"?`},
+		{input: "ghosty", output: `did you mean "ghostty"?`},
+		{input: "emacs", output: "available configs: alacritty"},
+	}
+
+	for _, test := range sadTests {
+		_, err := filterConfigs(Configs, nil, []string{test.input})
+
+		if err == nil || !strings.Contains(err.Error(), test.output) {
+			t.Errorf("Expected error containing %q for %q; err: %v", test.output, test.input, err)
+		}
+	}
+}
+
+func TestSplitConfigNames(t *testing.T) {
+	names := splitConfigNames(" nvim, ghostty,,zellij ")
+
+	if strings.Join(names, "|") != "nvim|ghostty|zellij" {
+		t.Errorf("Split names (%v) not as expected", names)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"time"
+)
+
+// Directions recorded in `ConfigState.Direction`
+const (
+	DirectionDownstream = "downstream"
+	DirectionUpstream   = "upstream"
+)
+
+/*
+ * SyncState
+ *
+ * What configpp remembers between runs, stored as JSON in `getStateDir()`/state.json.
+ * `Machines` is keyed by hostname, so a state directory shared between machines (or
+ * copied from an old one) never mixes up their syncs, and each machine's `Configs` is
+ * keyed by config name.
+ */
+type SyncState struct {
+	Machines map[string]MachineState `json:"machines"`
+}
+
+type MachineState struct {
+	Configs map[string]ConfigState `json:"configs"`
+}
+
+/*
+ * ConfigState
+ *
+ * `LastSync` and `Direction` describe the last time the config was copied, and `Commit`
+ * is the ConfigsSrc commit it was copied from (downstream) or onto (upstream).
+ * `Files` maps each file, relative to the config's root ("." for a single file config),
+ * to the SHA-256 of its content when the repo and installed copies last matched.
+ */
+type ConfigState struct {
+	LastSync  time.Time         `json:"lastSync"`
+	Direction string            `json:"direction"`
+	Commit    string            `json:"commit,omitempty"`
+	Files     map[string]string `json:"files"`
+}
+
+/*
+ * Returns this machine's state of a config; the zero value when it was never synced.
+ */
+func (state SyncState) config(name string) ConfigState {
+	return state.Machines[getHostname()].Configs[name]
+}
+
+/*
+ * Replaces this machine's state of a config.
+ */
+func (state *SyncState) setConfig(name string, configState ConfigState) {
+	hostname := getHostname()
+
+	machine, ok := state.Machines[hostname]
+	if !ok || machine.Configs == nil {
+		machine = MachineState{Configs: map[string]ConfigState{}}
+	}
+	machine.Configs[name] = configState
+
+	state.Machines[hostname] = machine
+}
+
+func getStatePath() string {
+	return path.Join(getStateDir(), "state.json")
+}
+
+/*
+ * Returns configpp's state directory, $XDG_STATE_HOME/configpp, defaulting to
+ * ~/.local/state/configpp.
+ */
+func getStateDir() string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		stateHome = path.Join(getHomePath(), ".local", "state")
+	}
+
+	return path.Join(stateHome, "configpp")
+}
+
+/*
+ * Returns the SHA-256 of every file under `root` the matcher does not exclude,
+ * keyed like `collectFiles`.
+ */
+func hashTree(root string, matcher IgnoreMatcher) (map[string]string, error) {
+	files, err := collectFiles(root, matcher)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := map[string]string{}
+	for rel, filePath := range files {
+		hash, err := hashFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		hashes[rel] = hex.EncodeToString(hash)
+	}
+
+	return hashes, nil
+}
+
+/*
+ * Returns the saved state, or an empty state when nothing has been synced yet.
+ */
+func loadState() (SyncState, error) {
+	state := SyncState{Machines: map[string]MachineState{}}
+
+	data, err := os.ReadFile(getStatePath())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, err
+	}
+	if state.Machines == nil {
+		state.Machines = map[string]MachineState{}
+	}
+
+	return state, nil
+}
+
+/*
+ * Records `commit`, the commit a push just made (or HEAD, when there was nothing to
+ * commit), as the commit the upstream syncs of the configs in this run landed onto.
+ * Only syncs still waiting for their commit are updated, so configs the run skipped
+ * keep theirs.
+ */
+func recordPushCommit(configs []Config, commit string) error {
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		configState := state.config(config.name)
+		if configState.Direction != DirectionUpstream || configState.Commit != "" {
+			continue
+		}
+
+		configState.Commit = commit
+		state.setConfig(config.name, configState)
+	}
+
+	return saveState(state)
+}
+
+/*
+ * Records a sync of the config: when, in which direction, from or onto which commit,
+ * and the files that are identical in the repo and installed copies as the new base
+ * for conflict detection.
+ *
+ * The files in `keep` were deliberately left different, so their previous base is kept
+ * and the conflict is reported again until it is resolved on both sides.
+ */
+func recordSync(state *SyncState, config Config, direction string, commit string, keep []string) error {
+	repoHashes, installHashes, err := hashConfig(config)
+	if err != nil {
+		return err
+	}
+
+	files := map[string]string{}
+	for rel, hash := range repoHashes {
+		if installHashes[rel] == hash {
+			files[rel] = hash
+		}
+	}
+
+	previous := state.config(config.name)
+	for _, rel := range keep {
+		if hash, ok := previous.Files[rel]; ok {
+			files[rel] = hash
+		}
+	}
+
+	state.setConfig(config.name, ConfigState{
+		LastSync:  time.Now(),
+		Direction: direction,
+		Commit:    commit,
+		Files:     files,
+	})
+
+	return nil
+}
+
+func saveState(state SyncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(getStateDir(), 0755); err != nil {
+		return err
+	}
+
+	// Write then rename so an interrupted run never leaves half a state file behind
+	tmpPath := getStatePath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, getStatePath())
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRecordSync(t *testing.T) {
+	conflictSandbox(t, func(config Config, state SyncState) {
+		before := time.Now()
+
+		if err := recordSync(&state, config, DirectionUpstream, "abc123", nil); err != nil {
+			t.Fatalf("Unexpected error recording a sync: %v", err)
+		}
+		if err := saveState(state); err != nil {
+			t.Fatalf("Unexpected error saving state: %v", err)
+		}
+
+		loaded, err := loadState()
+		if err != nil {
+			t.Fatalf("Unexpected error loading state: %v", err)
+		}
+
+		configState := loaded.config(config.name)
+		if configState.Direction != DirectionUpstream || configState.Commit != "abc123" || configState.LastSync.Before(before) {
+			t.Errorf("Recorded sync (%+v) not as expected", configState)
+		}
+		if len(configState.Files) != 2 || configState.Files["init.lua"] == "" {
+			t.Errorf("Recorded files (%v) not as expected", configState.Files)
+		}
+
+		// Other machines' syncs survive a save and stay apart from this machine's
+		loaded.Machines["other-machine"] = MachineState{Configs: map[string]ConfigState{"zsh": {Direction: DirectionDownstream, Commit: "def456"}}}
+		if err := saveState(loaded); err != nil {
+			t.Fatalf("Unexpected error saving state: %v", err)
+		}
+
+		reloaded, err := loadState()
+		if err != nil {
+			t.Fatalf("Unexpected error loading state: %v", err)
+		}
+
+		other := reloaded.Machines["other-machine"].Configs
+		if len(other) != 1 || other["zsh"].Direction != DirectionDownstream || other["zsh"].Commit != "def456" {
+			t.Errorf("Expected other-machine to only have zsh recorded; configs: %+v", other)
+		}
+		local := reloaded.Machines[getHostname()].Configs
+		if _, ok := local["zsh"]; ok || len(local) != 1 || local[config.name].Commit != "abc123" {
+			t.Errorf("Expected this machine to only have %s recorded; configs: %+v", config.name, local)
+		}
+	})
+}
+
+func TestRecordPushCommit(t *testing.T) {
+	conflictSandbox(t, func(config Config, state SyncState) {
+		pulled := config
+		pulled.name = "pulled"
+
+		recordSync(&state, config, DirectionUpstream, "", nil)
+		recordSync(&state, pulled, DirectionDownstream, "abc123", nil)
+		saveState(state)
+
+		if err := recordPushCommit([]Config{config, pulled}, "def456"); err != nil {
+			t.Fatalf("Unexpected error recording the push commit: %v", err)
+		}
+
+		loaded, _ := loadState()
+		if commit := loaded.config(config.name).Commit; commit != "def456" {
+			t.Errorf("Expected the pushed config to record the push commit; commit: %q", commit)
+		}
+		if commit := loaded.config(pulled.name).Commit; commit != "abc123" {
+			t.Errorf("Expected the pulled config to keep its commit; commit: %q", commit)
+		}
+	})
+}
+
+func TestLoadState(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	// Nothing synced yet
+	state, err := loadState()
+	if err != nil || len(state.Machines) != 0 {
+		t.Errorf("Expected an empty state; state: %+v; err: %v", state, err)
+	}
+
+	// Sad path - corrupt state
+	os.MkdirAll(getStateDir(), 0755)
+	os.WriteFile(getStatePath(), []byte("{"), 0644)
+	if _, err := loadState(); err == nil {
+		t.Errorf("Expected an error for a corrupt state file")
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Values of `ConfigStatus.status`
+const (
+	StatusInSync     = "in sync"
+	StatusLocalAhead = "local ahead"
+	StatusRepoAhead  = "repo ahead"
+	StatusDiverged   = "diverged"
+	StatusMissing    = "missing"
+	StatusUnlinked   = "unlinked"
+)
+
+/*
+ * ConfigStatus
+ *
+ * How the repo and installed copies of a config compare. `localChanged` files only
+ * changed in the install path since the last sync, `repoChanged` only in the repo, and
+ * `diverged` in both. `uncommitted` counts the paths of the config git reports as
+ * changed, and is -1 when the config is not in ConfigsSrc.
+ */
+type ConfigStatus struct {
+	config       string
+	status       string
+	detail       string
+	localChanged []string
+	repoChanged  []string
+	diverged     []string
+	uncommitted  int
+	lastSync     ConfigState
+}
+
+/*
+ * Sorts each file that differs between the repo and installed copies by which side
+ * changed it since `base`, the hashes recorded by the last sync.
+ *
+ * A file without a recorded hash is attributed to the only side that has it, and is
+ * diverged when both sides have different copies because there is no way to tell
+ * which side changed.
+ */
+func classifyDrift(base map[string]string, repoHashes map[string]string, installHashes map[string]string) ([]string, []string, []string) {
+	var localChanged, repoChanged, diverged []string
+
+	files := map[string]string{}
+	for rel := range repoHashes {
+		files[rel] = ""
+	}
+	for rel := range installHashes {
+		files[rel] = ""
+	}
+
+	for _, rel := range sortedKeys(files) {
+		repoHash, inRepo := repoHashes[rel]
+		installHash, installed := installHashes[rel]
+		if repoHash == installHash {
+			continue
+		}
+
+		baseHash, synced := base[rel]
+		switch {
+		case synced && repoHash == baseHash, !synced && !inRepo:
+			localChanged = append(localChanged, rel)
+		case synced && installHash == baseHash, !synced && !installed:
+			repoChanged = append(repoChanged, rel)
+		default:
+			diverged = append(diverged, rel)
+		}
+	}
+
+	return localChanged, repoChanged, diverged
+}
+
+/*
+ * Returns the drift between the repo and installed copies of a config, and how many
+ * of its paths git reports as changed.
+ */
+func getConfigStatus(state SyncState, config Config, git GitStatus) (ConfigStatus, error) {
+	status := ConfigStatus{
+		config:      config.name,
+		uncommitted: countUncommitted(git, config),
+		lastSync:    state.config(config.name),
+	}
+
+	installPath, err := getOSSpecificDestionationPath(config)
+	if err != nil {
+		status.status, status.detail = StatusMissing, "not installed on this machine"
+		return status, nil
+	}
+
+	// A linked config is in sync by definition, unless its link is missing or wrong
+	if config.mode == ModeSymlink {
+		if err := checkLink(config, installPath); err != nil {
+			status.status, status.detail = StatusUnlinked, err.Error()
+			return status, nil
+		}
+	}
+
+	repoMissing := !pathExists(config.localDotfilesRepoPath)
+	installMissing := !pathExists(installPath)
+	switch {
+	case repoMissing && installMissing:
+		status.status, status.detail = StatusMissing, "missing in the repo and install path"
+		return status, nil
+	case repoMissing:
+		status.status, status.detail = StatusMissing, "missing in the repo"
+		return status, nil
+	case installMissing:
+		status.status, status.detail = StatusMissing, "not installed"
+		return status, nil
+	}
+
+	repoHashes, installHashes, err := hashConfig(config)
+	if err != nil {
+		return status, err
+	}
+
+	status.localChanged, status.repoChanged, status.diverged = classifyDrift(status.lastSync.Files, repoHashes, installHashes)
+
+	var details []string
+	if len(status.localChanged) > 0 {
+		details = append(details, fmt.Sprintf("%d changed locally", len(status.localChanged)))
+	}
+	if len(status.repoChanged) > 0 {
+		details = append(details, fmt.Sprintf("%d changed in the repo", len(status.repoChanged)))
+	}
+	if len(status.diverged) > 0 {
+		details = append(details, fmt.Sprintf("%d changed on both sides", len(status.diverged)))
+	}
+	status.detail = strings.Join(details, ", ")
+
+	switch {
+	case len(status.diverged) > 0, len(status.localChanged) > 0 && len(status.repoChanged) > 0:
+		status.status = StatusDiverged
+	case len(status.localChanged) > 0:
+		status.status = StatusLocalAhead
+	case len(status.repoChanged) > 0:
+		status.status = StatusRepoAhead
+	default:
+		status.status = StatusInSync
+	}
+
+	return status, nil
+}
+
+/*
+ * Returns how many paths inside the config's repo path git reports as staged,
+ * unstaged, untracked, or unmerged, or -1 when the config is not in ConfigsSrc.
+ */
+func countUncommitted(git GitStatus, config Config) int {
+	rel, ok := strings.CutPrefix(config.localDotfilesRepoPath, ConfigsSrc+"/")
+	if !ok {
+		return -1
+	}
+
+	seen := map[string]bool{}
+	for _, paths := range [][]string{git.Staged, git.Unstaged, git.Untracked, git.Unmerged} {
+		for _, changed := range paths {
+			// NOTE: untracked directories are reported once, with a trailing "/"
+			changed = strings.TrimSuffix(changed, "/")
+			if changed == rel || strings.HasPrefix(changed, rel+"/") || strings.HasPrefix(rel, changed+"/") {
+				seen[changed] = true
+			}
+		}
+	}
+
+	return len(seen)
+}
+
+func pathExists(filePath string) bool {
+	_, err := os.Lstat(filePath)
+
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+/*
+ * Prints the branch of ConfigsSrc and how far it is from its upstream.
+ */
+func printGitStatus(out io.Writer, git GitStatus) {
+	fmt.Fprintf(out, "[%s] on %s", ConfigsSrc, git.Branch)
+
+	if git.Upstream == "" {
+		fmt.Fprintf(out, " without an upstream branch")
+	} else if git.Ahead == 0 && git.Behind == 0 {
+		fmt.Fprintf(out, ", up to date with %s", git.Upstream)
+	} else {
+		fmt.Fprintf(out, ", %d commit(s) ahead and %d behind %s", git.Ahead, git.Behind, git.Upstream)
+	}
+
+	changes := len(git.Staged) + len(git.Unstaged) + len(git.Untracked) + len(git.Unmerged)
+	if changes == 0 {
+		fmt.Fprintf(out, "; nothing to commit\n")
+	} else {
+		fmt.Fprintf(out, "; %d staged, %d unstaged, %d untracked, %d unmerged\n", len(git.Staged), len(git.Unstaged), len(git.Untracked), len(git.Unmerged))
+	}
+}
+
+/*
+ * Prints a line per config: how its copies compare, why, how many of its paths are
+ * uncommitted, and when it was last synced on this machine.
+ */
+func printStatusTable(out io.Writer, statuses []ConfigStatus) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "NAME\tSTATUS\tUNCOMMITTED\tLAST SYNC\tDETAILS\n")
+
+	for _, status := range statuses {
+		uncommitted := "-"
+		if status.uncommitted >= 0 {
+			uncommitted = strconv.Itoa(status.uncommitted)
+		}
+
+		lastSync := "never"
+		if !status.lastSync.LastSync.IsZero() {
+			lastSync = status.lastSync.LastSync.Local().Format("2006-01-02 15:04") + " " + status.lastSync.Direction
+			if commit := status.lastSync.Commit; len(commit) >= 7 {
+				lastSync += " @" + commit[:7]
+			}
+		}
+
+		detail := status.detail
+		if detail == "" {
+			detail = "-"
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", status.config, status.status, uncommitted, lastSync, detail)
+	}
+
+	return writer.Flush()
+}
+
+/*
+ * `configpp status [config...]` prints the state of ConfigsSrc and, for every config,
+ * whether its repo and installed copies are in sync, which side is ahead, whether
+ * they diverged, or whether one is missing.
+ */
+func runStatus(names []string) error {
+	configs, err := loadConfigs(names)
+	if err != nil {
+		return err
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return fmt.Errorf("loading sync state: %w", err)
+	}
+
+	client, err := getConfigsGit()
+	if err != nil {
+		return err
+	}
+
+	git, err := client.status()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading git status of [%s]: %v\n", ConfigsSrc, err)
+	} else {
+		printGitStatus(os.Stdout, git)
+		if git.Branch != client.branch {
+			fmt.Printf("configpp syncs %s/%s, so pull and push will refuse to run until it is checked out\n", client.remote, client.branch)
+		}
+		fmt.Printf("\n")
+	}
+
+	gitErr := err
+
+	var statuses []ConfigStatus
+	for _, config := range configs {
+		status, err := getConfigStatus(state, config, git)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error comparing [%s]: %v\n", config.name, err)
+			continue
+		}
+		if gitErr != nil {
+			status.uncommitted = -1
+		}
+		statuses = append(statuses, status)
+	}
+
+	return printStatusTable(os.Stdout, statuses)
+}
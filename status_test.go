@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyDrift(t *testing.T) {
+	base := map[string]string{"same": "a", "local": "a", "repo": "a", "both": "a", "deleted": "a"}
+	repoHashes := map[string]string{"same": "a", "local": "a", "repo": "b", "both": "b", "deleted": "a", "new-repo": "c", "new-both": "c"}
+	installHashes := map[string]string{"same": "a", "local": "b", "repo": "a", "both": "c", "new-installed": "c", "new-both": "d"}
+
+	localChanged, repoChanged, diverged := classifyDrift(base, repoHashes, installHashes)
+
+	if strings.Join(localChanged, ",") != "deleted,local,new-installed" {
+		t.Errorf("Files changed locally (%v) not as expected", localChanged)
+	}
+	if strings.Join(repoChanged, ",") != "new-repo,repo" {
+		t.Errorf("Files changed in the repo (%v) not as expected", repoChanged)
+	}
+	if strings.Join(diverged, ",") != "both,new-both" {
+		t.Errorf("Diverged files (%v) not as expected", diverged)
+	}
+}
+
+func TestGetConfigStatus(t *testing.T) {
+	conflictSandbox(t, func(config Config, state SyncState) {
+		status, err := getConfigStatus(state, config, GitStatus{})
+		if err != nil || status.status != StatusInSync {
+			t.Errorf("Expected %s; status: %+v; err: %v", StatusInSync, status, err)
+		}
+
+		executeCommand(config.localInstallPath[DefaultInstallKey], "bash", "-c", "echo local > init.lua")
+
+		status, _ = getConfigStatus(state, config, GitStatus{})
+		if status.status != StatusLocalAhead || status.detail != "1 changed locally" {
+			t.Errorf("Expected %s; status: %+v", StatusLocalAhead, status)
+		}
+
+		executeCommand(config.localDotfilesRepoPath, "bash", "-c", "echo repo > other.lua")
+
+		status, _ = getConfigStatus(state, config, GitStatus{})
+		if status.status != StatusDiverged {
+			t.Errorf("Expected %s when each side changed a file; status: %+v", StatusDiverged, status)
+		}
+
+		config.localInstallPath = InstallPaths{DefaultInstallKey: config.localInstallPath[DefaultInstallKey] + "-missing"}
+
+		status, _ = getConfigStatus(state, config, GitStatus{})
+		if status.status != StatusMissing || status.detail != "not installed" {
+			t.Errorf("Expected %s; status: %+v", StatusMissing, status)
+		}
+	})
+}
+
+func TestPrintStatusTable(t *testing.T) {
+	var out bytes.Buffer
+	err := printStatusTable(&out, []ConfigStatus{
+		{config: "nvim", status: StatusLocalAhead, detail: "1 changed locally", uncommitted: 2, lastSync: ConfigState{
+			LastSync:  time.Date(2025, 8, 18, 9, 30, 0, 0, time.Local),
+			Direction: DirectionDownstream,
+			Commit:    "0123456789abcdef",
+		}},
+		{config: "zellij", status: StatusInSync, uncommitted: -1},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and a line per config:\n%s", out.String())
+	}
+	if strings.Join(strings.Fields(lines[1]), " ") != "nvim local ahead 2 2025-08-18 09:30 downstream @0123456 1 changed locally" {
+		t.Errorf("nvim line (%s) not as expected", lines[1])
+	}
+	if strings.Join(strings.Fields(lines[2]), " ") != "zellij in sync - never -" {
+		t.Errorf("zellij line (%s) not as expected", lines[2])
+	}
+}
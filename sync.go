@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+ * SyncOptions
+ *
+ * `Include` and `Exclude` hold gitignore-style patterns (see `IgnoreMatcher`) matched
+ * against paths relative to `src`; excluded directories are skipped entirely.
+ * `Checksum` compares file content instead of size and modification time.
+ * `DryRun` reports what would change without touching the destination.
+ * `Render` installs templates (see `TemplateSuffix`) rendered with `Variables` instead
+ * of copying them, and `ProtectTemplates` never overwrites a template in `dest` with
+ * the file rendered from it.
+ * `Decrypt` installs secrets (see `SecretCipher`) decrypted, and `Encrypt` re-encrypts
+ * files into the secrets in `dest` they were decrypted from.
+ */
+type SyncOptions struct {
+	Include          []string
+	Exclude          []string
+	Checksum         bool
+	DryRun           bool
+	Render           bool
+	ProtectTemplates bool
+	Variables        map[string]string
+	Decrypt          bool
+	Encrypt          bool
+}
+
+/*
+ * Copies `src` to `dest`, where both name the file or directory itself, so a
+ * directory is synced into `dest` rather than into a child of it.
+ *
+ * Only files that changed are copied, and every copied file, directory, and symlink
+ * keeps its permissions and modification time. Files only present in `dest` are left
+ * alone.
+ */
+func syncPath(src string, dest string, options SyncOptions) (ChangeSummary, error) {
+	summary := ChangeSummary{Root: dest}
+
+	info, err := os.Lstat(src)
+	if err != nil {
+		return summary, fmt.Errorf("cannot sync [%s]: %w", src, err)
+	}
+
+	matcher, err := newIgnoreMatcher(options.Include, options.Exclude)
+	if err != nil {
+		return summary, err
+	}
+
+	if !info.IsDir() {
+		// A single file is reported relative to the directory it lives in
+		summary.Root = path.Dir(dest)
+		err := syncEntry(src, dest, path.Base(dest), info, options, &summary)
+
+		return summary, err
+	}
+
+	err = filepath.WalkDir(src, func(srcPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, srcPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if srcPath != src && matcher.excluded(rel, entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		return syncEntry(srcPath, path.Join(dest, rel), rel, info, options, &summary)
+	})
+
+	return summary, err
+}
+
+/*
+ * Syncs a single directory, file, or symlink and records the result in the summary.
+ */
+func syncEntry(srcPath string, destPath string, rel string, info fs.FileInfo, options SyncOptions, summary *ChangeSummary) error {
+	destInfo, statErr := os.Lstat(destPath)
+	exists := statErr == nil
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return statErr
+	}
+
+	if info.IsDir() {
+		if exists && !destInfo.IsDir() {
+			return fmt.Errorf("cannot sync directory [%s] over non-directory [%s]", srcPath, destPath)
+		}
+		if !options.DryRun {
+			if err := os.MkdirAll(destPath, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return os.Chmod(destPath, info.Mode().Perm())
+		}
+		return nil
+	}
+
+	if options.Render && info.Mode().IsRegular() && strings.HasSuffix(srcPath, TemplateSuffix) {
+		rendered, err := renderTemplate(srcPath, options.Variables)
+		if err != nil {
+			return err
+		}
+		return syncContent(rendered, info.Mode().Perm(), strings.TrimSuffix(destPath, TemplateSuffix), strings.TrimSuffix(rel, TemplateSuffix), options, summary)
+	}
+
+	// Decrypted secrets are only readable by their owner, whatever the repo's permissions
+	if options.Decrypt && info.Mode().IsRegular() && isSecret(srcPath) {
+		plaintext, err := readSecret(srcPath)
+		if err != nil {
+			return err
+		}
+		return syncContent(plaintext, 0600, trimSecretSuffix(destPath), trimSecretSuffix(rel), options, summary)
+	}
+
+	if options.ProtectTemplates && info.Mode().IsRegular() {
+		if templatePath, ok := findTemplate(destPath); ok {
+			return protectTemplate(srcPath, templatePath, rel, options, summary)
+		}
+	}
+
+	if options.Encrypt && info.Mode().IsRegular() {
+		if secretPath, ok := findSecret(destPath); ok {
+			return syncEncrypted(srcPath, secretPath, rel, options, summary)
+		}
+	}
+
+	if exists && destInfo.IsDir() {
+		return fmt.Errorf("cannot sync file [%s] over directory [%s]", srcPath, destPath)
+	}
+
+	changed := !exists
+	if exists {
+		var err error
+		changed, err = hasChanged(srcPath, destPath, info, destInfo, options.Checksum)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case !exists:
+		summary.Added = append(summary.Added, rel)
+	case changed:
+		summary.Modified = append(summary.Modified, rel)
+	default:
+		summary.Unchanged++
+		return nil
+	}
+
+	if options.DryRun {
+		return nil
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return copySymlink(srcPath, destPath)
+	}
+
+	// Replace a symlink instead of writing through it
+	if exists && destInfo.Mode()&fs.ModeSymlink != 0 {
+		if err := os.Remove(destPath); err != nil {
+			return err
+		}
+	}
+
+	if err := copyFile(srcPath, destPath); err != nil {
+		return err
+	}
+
+	return os.Chtimes(destPath, info.ModTime(), info.ModTime())
+}
+
+/*
+ * Writes content generated from a repo file, such as a rendered template or a decrypted
+ * secret, into `destPath`, only when the content or permissions differ from what is
+ * installed.
+ */
+func syncContent(content []byte, perm fs.FileMode, destPath string, rel string, options SyncOptions, summary *ChangeSummary) error {
+	destInfo, statErr := os.Lstat(destPath)
+	exists := statErr == nil
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return statErr
+	}
+	if exists && destInfo.IsDir() {
+		return fmt.Errorf("cannot sync [%s] over directory [%s]", rel, destPath)
+	}
+
+	changed := !exists || destInfo.Mode()&fs.ModeSymlink != 0 || destInfo.Mode().Perm() != perm
+	if exists && !changed {
+		installed, err := os.ReadFile(destPath)
+		if err != nil {
+			return err
+		}
+		changed = !bytes.Equal(content, installed)
+	}
+
+	switch {
+	case !exists:
+		summary.Added = append(summary.Added, rel)
+	case changed:
+		summary.Modified = append(summary.Modified, rel)
+	default:
+		summary.Unchanged++
+		return nil
+	}
+
+	if options.DryRun {
+		return nil
+	}
+
+	if err := os.MkdirAll(path.Dir(destPath), 0755); err != nil {
+		return err
+	}
+	if exists {
+		if err := os.Remove(destPath); err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(destPath, content, perm)
+}
+
+/*
+ * Encrypts a file into the secret it was decrypted from. The secret is only rewritten
+ * when the plaintext changed, since encrypting the same content twice never produces
+ * the same file and would leave a change to commit after every push.
+ */
+func syncEncrypted(srcPath string, secretPath string, rel string, options SyncOptions, summary *ChangeSummary) error {
+	plaintext, err := os.ReadFile(srcPath)
+	if err != nil {
+		return err
+	}
+
+	previous, err := readSecret(secretPath)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(plaintext, previous) {
+		summary.Unchanged++
+		return nil
+	}
+
+	summary.Modified = append(summary.Modified, rel)
+	if options.DryRun {
+		return nil
+	}
+
+	secretCipher, err := getSecretCipher(secretPath)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := secretCipher.encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("encrypting [%s]: %w", secretPath, err)
+	}
+
+	return os.WriteFile(secretPath, ciphertext, 0644)
+}
+
+/*
+ * Leaves the template that renders a file alone. When the file no longer matches what
+ * the template renders, it was edited after being installed, and it is reported so the
+ * edit can be made to the template instead.
+ */
+func protectTemplate(srcPath string, templatePath string, rel string, options SyncOptions, summary *ChangeSummary) error {
+	rendered, err := renderTemplate(templatePath, options.Variables)
+	if err != nil {
+		return err
+	}
+
+	installed, err := os.ReadFile(srcPath)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(rendered, installed) {
+		summary.Unchanged++
+	} else {
+		summary.Templated = append(summary.Templated, rel)
+	}
+
+	return nil
+}
+
+/*
+ * Copies a single file, creating the destination's directories and keeping the
+ * source's permissions.
+ */
+func copyFile(src string, dest string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	destFile, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(destFile, srcFile); err != nil {
+		destFile.Close()
+		return err
+	}
+
+	if err := destFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Chmod(dest, info.Mode().Perm())
+}
+
+func copySymlink(srcPath string, destPath string) error {
+	target, err := os.Readlink(srcPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(destPath), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Remove(destPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Symlink(target, destPath)
+}
+
+/*
+ * Returns the SHA-256 of a file's content.
+ */
+func hashFile(filePath string) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+/*
+ * Returns whether `srcPath` differs from `destPath`. Like rsync's quick check, files
+ * with the same size and modification time are assumed equal unless `checksum` is set.
+ */
+func hasChanged(srcPath string, destPath string, info fs.FileInfo, destInfo fs.FileInfo, checksum bool) (bool, error) {
+	isLink := info.Mode()&fs.ModeSymlink != 0
+	if isLink != (destInfo.Mode()&fs.ModeSymlink != 0) {
+		return true, nil
+	}
+
+	if isLink {
+		srcTarget, err := os.Readlink(srcPath)
+		if err != nil {
+			return false, err
+		}
+		destTarget, err := os.Readlink(destPath)
+
+		return srcTarget != destTarget, err
+	}
+
+	if info.Size() != destInfo.Size() {
+		return true, nil
+	}
+
+	if info.Mode().Perm() != destInfo.Mode().Perm() {
+		return true, nil
+	}
+
+	if !checksum {
+		return !info.ModTime().Equal(destInfo.ModTime()), nil
+	}
+
+	srcHash, err := hashFile(srcPath)
+	if err != nil {
+		return false, err
+	}
+	destHash, err := hashFile(destPath)
+	if err != nil {
+		return false, err
+	}
+
+	return !bytes.Equal(srcHash, destHash), nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncPath(t *testing.T) {
+	src, err := os.MkdirTemp("", "sync_src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+
+	destParent, err := os.MkdirTemp("", "sync_dest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destParent)
+
+	// The destination, including its parent directories, does not exist yet
+	dest := destParent + "/.config/nvim"
+
+	executeCommand(src, "bash", "-c", "mkdir -p .git lua && echo init > init.lua && echo plugins > lua/plugins.lua && touch .git/HEAD && chmod 0600 init.lua && ln -s init.lua link.lua")
+
+	/*
+	 * Dry run reports everything as added without creating the destination
+	 */
+	summary, err := syncPath(src, dest, SyncOptions{DryRun: true, Exclude: []string{".git"}})
+	if err != nil {
+		t.Fatalf("Unexpected error during a dry run: %v", err)
+	}
+	if strings.Join(summary.Added, ",") != "init.lua,link.lua,lua/plugins.lua" {
+		t.Errorf("Dry run added files (%v) not as expected", summary.Added)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("Expected a dry run not to create [%s]", dest)
+	}
+
+	/*
+	 * Copy into the missing directory, excluding .git and preserving permissions and symlinks
+	 */
+	summary, err = syncPath(src, dest, SyncOptions{Exclude: []string{".git"}})
+	if err != nil {
+		t.Fatalf("Unexpected error syncing: %v", err)
+	}
+	if len(summary.Added) != 3 || summary.Root != dest {
+		t.Errorf("Summary not as expected: %+v", summary)
+	}
+
+	if _, err := os.Stat(dest + "/.git"); !os.IsNotExist(err) {
+		t.Errorf("Expected .git to be excluded")
+	}
+
+	info, err := os.Stat(dest + "/init.lua")
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected init.lua to keep its 0600 permissions; err: %v", err)
+	}
+
+	if target, err := os.Readlink(dest + "/link.lua"); err != nil || target != "init.lua" {
+		t.Errorf("Expected link.lua to be copied as a symlink to init.lua; target: %s; err: %v", target, err)
+	}
+
+	/*
+	 * Nothing changed, so nothing is copied
+	 */
+	summary, err = syncPath(src, dest, SyncOptions{Exclude: []string{".git"}})
+	if err != nil || !summary.isEmpty() || summary.Unchanged != 3 {
+		t.Errorf("Expected 3 unchanged files; summary: %+v; err: %v", summary, err)
+	}
+
+	/*
+	 * Same size and modification time, different content: only a checksum notices
+	 */
+	executeCommand(src, "bash", "-c", "echo PLUGINS > lua/plugins.lua")
+	modTime := time.Now().Add(-time.Hour)
+	os.Chtimes(src+"/lua/plugins.lua", modTime, modTime)
+	os.Chtimes(dest+"/lua/plugins.lua", modTime, modTime)
+
+	summary, _ = syncPath(src, dest, SyncOptions{Exclude: []string{".git"}})
+	if !summary.isEmpty() {
+		t.Errorf("Expected the quick check to miss the change; summary: %+v", summary)
+	}
+
+	summary, _ = syncPath(src, dest, SyncOptions{Exclude: []string{".git"}, Checksum: true})
+	if strings.Join(summary.Modified, ",") != "lua/plugins.lua" {
+		t.Errorf("Expected the checksum to catch the change; summary: %+v", summary)
+	}
+
+	/*
+	 * Single files sync to the exact destination path
+	 */
+	summary, err = syncPath(src+"/init.lua", destParent+"/vim/.vimrc", SyncOptions{})
+	if err != nil || strings.Join(summary.Added, ",") != ".vimrc" || summary.Root != destParent+"/vim" {
+		t.Errorf("Single file summary not as expected: %+v; err: %v", summary, err)
+	}
+
+	/*
+	 * Sad path - missing source
+	 */
+	if _, err := syncPath(src+"/does-not-exist", dest, SyncOptions{}); err == nil {
+		t.Errorf("Expected an error syncing a missing source")
+	}
+}
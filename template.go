@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// Repo files ending with TemplateSuffix are rendered into the install path without it
+const TemplateSuffix = ".tmpl"
+
+/*
+ * Returns the repo files of a config keyed like `collectFiles`, except templates and
+ * secrets are keyed by the file they install, i.e. "config.tmpl" as "config".
+ */
+func collectRepoFiles(config Config, matcher IgnoreMatcher) (map[string]string, error) {
+	files, err := collectFiles(config.localDotfilesRepoPath, matcher)
+	if err != nil {
+		return nil, err
+	}
+
+	repoFiles := map[string]string{}
+	for rel, filePath := range files {
+		if rendered, ok := strings.CutSuffix(rel, TemplateSuffix); ok {
+			repoFiles[rendered] = filePath
+			continue
+		}
+		if isSecret(rel) {
+			repoFiles[trimSecretSuffix(rel)] = filePath
+			continue
+		}
+
+		// NOTE: a template or secret takes precedence over a plain file it would install over
+		if _, ok := repoFiles[rel]; !ok {
+			repoFiles[rel] = filePath
+		}
+	}
+
+	return repoFiles, nil
+}
+
+/*
+ * Returns the template in the repo that renders `repoPath`: the path itself when it is
+ * a template, else the path with `TemplateSuffix` when that exists.
+ */
+func findTemplate(repoPath string) (string, bool) {
+	if strings.HasSuffix(repoPath, TemplateSuffix) {
+		return repoPath, pathExists(repoPath)
+	}
+
+	templatePath := repoPath + TemplateSuffix
+
+	return templatePath, pathExists(templatePath)
+}
+
+/*
+ * Returns the SHA-256 of every repo file of a config, keyed like `collectRepoFiles`,
+ * hashing the rendered output of templates so they compare with their installed copies.
+ */
+func hashRepoTree(config Config, matcher IgnoreMatcher) (map[string]string, error) {
+	files, err := collectRepoFiles(config, matcher)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := map[string]string{}
+	for rel, filePath := range files {
+		content, err := readRepoFile(config, filePath)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(content)
+		hashes[rel] = hex.EncodeToString(hash[:])
+	}
+
+	return hashes, nil
+}
+
+/*
+ * Returns the content a repo file installs: templates are rendered with the config's
+ * variables, secrets are decrypted, and everything else is read as is.
+ */
+func readRepoFile(config Config, filePath string) ([]byte, error) {
+	if strings.HasSuffix(filePath, TemplateSuffix) {
+		return renderTemplate(filePath, config.variables)
+	}
+	if isSecret(filePath) {
+		return readSecret(filePath)
+	}
+
+	return os.ReadFile(filePath)
+}
+
+/*
+ * Renders a text/template file with the variables as its data, i.e. `{{ .email }}`.
+ * A variable the template uses but the manifest does not define is an error, so a
+ * half-rendered config is never installed.
+ */
+func renderTemplate(templatePath string, variables map[string]string) ([]byte, error) {
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(path.Base(templatePath)).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template [%s]: %w", templatePath, err)
+	}
+
+	if variables == nil {
+		variables = map[string]string{}
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, variables); err != nil {
+		return nil, fmt.Errorf("rendering template [%s]: %w", templatePath, err)
+	}
+
+	return rendered.Bytes(), nil
+}
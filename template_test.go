@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestSyncTemplates(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	repoDir := t.TempDir()
+	installDir := t.TempDir()
+	executeCommand(repoDir, "bash", "-c", "echo 'email = {{ .email }}' > gitconfig.tmpl && echo plain > plain")
+
+	config := Config{
+		name:                  "git",
+		dir:                   true,
+		localInstallPath:      InstallPaths{DefaultInstallKey: installDir},
+		localDotfilesRepoPath: repoDir,
+		variables:             map[string]string{"email": "me@work.com"},
+	}
+
+	// Downstream renders the template without its suffix
+	summary, err := cpConfig(config, false, false)
+	if err != nil {
+		t.Fatalf("Unexpected error copying templates: %v", err)
+	}
+	if strings.Join(summary.Added, ",") != "gitconfig,plain" {
+		t.Errorf("Added files (%v) not as expected", summary.Added)
+	}
+
+	rendered, _ := os.ReadFile(path.Join(installDir, "gitconfig"))
+	if string(rendered) != "email = me@work.com\n" {
+		t.Errorf("Rendered template (%q) not as expected", rendered)
+	}
+	if pathExists(path.Join(installDir, "gitconfig.tmpl")) {
+		t.Errorf("Expected the template itself not to be installed")
+	}
+
+	// Rendered files match their templates, so nothing differs
+	repoHashes, installHashes, err := hashConfig(config)
+	if err != nil || repoHashes["gitconfig"] != installHashes["gitconfig"] {
+		t.Errorf("Expected the template to hash as its output; repo: %v; installed: %v; err: %v", repoHashes, installHashes, err)
+	}
+
+	// A second run changes nothing
+	summary, err = cpConfig(config, false, false)
+	if err != nil || !summary.isEmpty() {
+		t.Errorf("Expected no changes rendering again; summary: %+v; err: %v", summary, err)
+	}
+
+	// Upstream never overwrites the template with its output, even once it was edited
+	os.WriteFile(path.Join(installDir, "gitconfig"), []byte("email = edited@work.com\n"), 0644)
+
+	summary, err = cpConfig(config, true, false)
+	if err != nil {
+		t.Fatalf("Unexpected error copying upstream: %v", err)
+	}
+	if strings.Join(summary.Templated, ",") != "gitconfig" || pathExists(path.Join(repoDir, "gitconfig")) {
+		t.Errorf("Expected the edited output to be reported instead of copied; summary: %+v", summary)
+	}
+
+	template, _ := os.ReadFile(path.Join(repoDir, "gitconfig.tmpl"))
+	if string(template) != "email = {{ .email }}\n" {
+		t.Errorf("Expected the template to be left alone; got %q", template)
+	}
+
+	// Sad path - an undefined variable
+	config.variables = nil
+	if _, err := cpConfig(config, false, true); err == nil {
+		t.Errorf("Expected an error rendering a template without its variables")
+	}
+}